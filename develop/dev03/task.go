@@ -2,13 +2,20 @@ package main
 
 import (
 	"bufio"
+	"container/heap"
+	crand "crypto/rand"
+	"encoding/binary"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"math"
+	"math/rand"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 /*
@@ -53,17 +60,147 @@ var (
 	//         Unique keys.  Suppress all lines that have a key that is equal to an already processed one.
 	// Реализовано именно такое поведение - исключение дубликатов по ключу, а не по содержанию оригинальной строки.
 	unique = flag.Bool("u", false, "keep only unique lines")
+
+	// Разделять записи нулевым байтом вместо перевода строки (как в sort -z, удобно для вывода find -print0)
+	nullTerminated = flag.Bool("z", false, "line delimiter is NUL, not newline")
+
+	// Сортировать по случайному хэшу ключа. Как и в GNU sort, строки с одинаковыми ключами оказываются рядом.
+	randomSort = flag.Bool("R", false, "sort by random hash of keys")
+
+	// Вывести строки в случайном порядке вне зависимости от ключей
+	shuffle = flag.Bool("shuffle", false, "output a random permutation of lines")
+
+	// Начальное значение генератора случайных чисел для -R и --shuffle. Позволяет воспроизвести порядок строк.
+	seed = flag.Uint64("seed", 0, "random seed for -R and --shuffle")
+
+	// Файл, первые байты которого используются как начальное значение генератора случайных чисел
+	randomSource = flag.String("random-source", "", "get random seed from file")
+
+	// Оставить только первые N строк отсортированного вывода. Вместе с --shuffle даёт случайную выборку из N строк.
+	headLimit = flag.Int("head", 0, "output only first N lines of sorted data")
+
+	// Оставить только последние N строк отсортированного вывода
+	tailLimit = flag.Int("tail", 0, "output only last N lines of sorted data")
+
+	// Вместо самих строк вывести по одной строке на каждый уникальный ключ со значениями агрегатов
+	groupBy = flag.Bool("group-by", false, "output one line per distinct key with aggregates")
+
+	// Индекс столбца со значениями для агрегатов, -1 для использования строки целиком
+	valueColumn = flag.Int("value", -1, "column index with values to aggregate")
+
+	// Список агрегатов через запятую, которые будут выведены для каждой группы
+	aggregateList = flag.String("aggregate", "count", "comma-separated aggregates: count,sum,min,max,mean,first,last")
+
+	// Подчёркивать ключ под каждой выведенной строкой и выводить предупреждения о строках, для которых ключ не удалось
+	// получить так, как было задано
+	debug = flag.Bool("debug", false, "annotate the part of the line used to sort")
+)
+
+// Aggregate - название функции, вычисляемой по значениям строк с одинаковым ключом в режиме --group-by.
+type Aggregate string
+
+const (
+	// AggregateCount - количество строк в группе
+	AggregateCount Aggregate = "count"
+
+	// AggregateSum - сумма числовых значений
+	AggregateSum Aggregate = "sum"
+
+	// AggregateMin - минимальное числовое значение
+	AggregateMin Aggregate = "min"
+
+	// AggregateMax - максимальное числовое значение
+	AggregateMax Aggregate = "max"
+
+	// AggregateMean - среднее арифметическое числовых значений
+	AggregateMean Aggregate = "mean"
+
+	// AggregateFirst - первое значение в группе в порядке сортировки
+	AggregateFirst Aggregate = "first"
+
+	// AggregateLast - последнее значение в группе в порядке сортировки
+	AggregateLast Aggregate = "last"
+)
+
+// ParseAggregates разбирает список агрегатов, перечисленных через запятую.
+func ParseAggregates(input string) ([]Aggregate, error) {
+	parts := strings.Split(input, ",")
+	result := make([]Aggregate, len(parts))
+
+	for i, part := range parts {
+		a := Aggregate(strings.TrimSpace(part))
+
+		switch a {
+		case AggregateCount, AggregateSum, AggregateMin, AggregateMax, AggregateMean, AggregateFirst, AggregateLast:
+			result[i] = a
+		default:
+			return nil, fmt.Errorf("unknown aggregate \"%s\"", part)
+		}
+	}
+
+	return result, nil
+}
+
+// Ordering определяет стратегию, по которой FileHolder упорядочивает строки.
+type Ordering int
+
+const (
+	// OrderByKey - обычная сортировка по значениям ключей
+	OrderByKey Ordering = iota
+
+	// OrderByRandomKey - сортировка по хэшу ключа, зависящему от seed. Строки с одинаковыми ключами идут подряд.
+	OrderByRandomKey
+
+	// OrderShuffle - случайная перестановка строк. Каждой строке при чтении присваивается случайный вес, по которому
+	// строки затем и сортируются.
+	OrderShuffle
 )
 
 // FileHolder хранит информацию о данных, которые были получены из файла, а также настройки для его сортировки.
-// Реализует sort.Interface, за счёт чего экземпляр этой структуры может быть передан в sort.Sort.
+// Реализует sort.Interface, сортировка выполняется методом FileHolder.Sort.
 type FileHolder struct {
 	lines []string
 
+	// Случайные веса строк, заполняются только при OrderShuffle. Индексы совпадают с индексами в lines.
+	weights []uint64
+	rng     *rand.Rand
+
+	// Была ли последняя запись во входных данных завершена разделителем. Если нет, то последнюю запись в выходных
+	// данных тоже выводим без разделителя.
+	missingFinalDelimiter bool
+
 	columnIndex     int
 	arithmeticValue bool
 	reverseOrder    bool
 	unique          bool
+	nullTerminated  bool
+	ordering        Ordering
+	seed            uint64
+
+	// Если задан один из лимитов, FileHolder во время чтения хранит не более headLimit (или tailLimit) лучших строк
+	// в виде двоичной кучи, а не все входные данные
+	headLimit int
+	tailLimit int
+
+	// Ключи строк, находящихся в куче. Используется для -u, чтобы дубликаты не занимали место в куче.
+	selectedKeys map[any]bool
+
+	// Порядковые номера строк кучи во входных данных, индексы совпадают с индексами в lines. По ним различаются строки
+	// с равными ключами, чтобы отбор был устойчивым, как у полной сортировки.
+	sequence []int
+	added    int
+
+	groupBy     bool
+	valueColumn int
+	aggregates  []Aggregate
+
+	debug bool
+
+	// Счётчики для Warnings, заполняются в режиме отладки во время чтения. Учитываются все прочитанные строки, в том
+	// числе не попавшие в кучу при --head и --tail.
+	readCount          int
+	missingColumnCount int
+	notANumberCount    int
 }
 
 // NewFileHolder создаёт новый пустой FileHolder. Для работы требуется далее вызвать метод FileHolder.ReadLines.
@@ -73,22 +210,220 @@ func NewFileHolder() *FileHolder {
 		arithmeticValue: *arithmeticValue,
 		reverseOrder:    *reverseOrder,
 		unique:          *unique,
+		nullTerminated:  *nullTerminated,
+		ordering:        SelectedOrdering(),
+		seed:            *seed,
+		headLimit:       *headLimit,
+		tailLimit:       *tailLimit,
+		groupBy:         *groupBy,
+		valueColumn:     *valueColumn,
+		aggregates:      []Aggregate{AggregateCount},
+		debug:           *debug,
 	}
 }
 
-// ReadLines считывает построчно данные из переданного reader и сохраняет строки в FileHolder.
-func (h *FileHolder) ReadLines(reader io.Reader) {
+// SelectedOrdering возвращает стратегию упорядочивания строк, выбранную флагами программы.
+func SelectedOrdering() Ordering {
+	switch {
+	case *shuffle:
+		return OrderShuffle
+	case *randomSort:
+		return OrderByRandomKey
+	default:
+		return OrderByKey
+	}
+}
+
+// RandomSeed возвращает начальное значение генератора случайных чисел. Если задан --random-source, значение читается
+// из указанного файла, иначе используется --seed. Если не задано ни то, ни другое, seed выбирается случайно.
+func RandomSeed() (uint64, error) {
+	buf := make([]byte, 8)
+
+	if *randomSource != "" {
+		file, err := os.Open(*randomSource)
+		if err != nil {
+			return 0, err
+		}
+
+		defer file.Close()
+
+		// Как и GNU sort, требуем, чтобы в файле было достаточно случайных байт
+		_, err = io.ReadFull(file, buf)
+		if err != nil {
+			return 0, fmt.Errorf("%s: not enough random bytes: %w", *randomSource, err)
+		}
+
+		return binary.LittleEndian.Uint64(buf), nil
+	}
+
+	// Проверяем, был ли флаг --seed передан явно, поскольку 0 - тоже допустимое значение
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			explicit = true
+		}
+	})
+
+	if explicit {
+		return *seed, nil
+	}
+
+	_, err := crand.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+// delimiter возвращает байт, которым разделяются записи во входных и выходных данных.
+func (h *FileHolder) delimiter() byte {
+	if h.nullTerminated {
+		return 0
+	}
+
+	return '\n'
+}
+
+// ReadLines считывает построчно данные из переданного reader и сохраняет строки в FileHolder. В отличие от
+// bufio.Scanner, длина одной строки ничем не ограничена. Ошибка чтения возвращается вызывающей стороне, а не
+// приводит к молчаливой потере оставшихся данных.
+func (h *FileHolder) ReadLines(reader io.Reader) error {
 	h.lines = make([]string, 0)
-	scanner := bufio.NewScanner(reader)
+	h.weights = nil
+	h.selectedKeys = make(map[any]bool)
+	h.sequence = nil
+	h.added = 0
+	h.missingFinalDelimiter = false
+	h.readCount, h.missingColumnCount, h.notANumberCount = 0, 0, 0
+
+	// Для перемешивания веса строк берём из генератора, инициализированного seed, чтобы перестановка была
+	// воспроизводимой
+	if h.ordering == OrderShuffle {
+		h.weights = make([]uint64, 0)
+		h.rng = rand.New(rand.NewSource(int64(h.seed)))
+	}
+
+	r := bufio.NewReader(reader)
+	delimiter := h.delimiter()
+
+	for {
+		line, err := r.ReadString(delimiter)
+
+		// ReadString возвращает данные вместе с разделителем, если он был найден. Запоминаем, завершалась ли запись
+		// разделителем, и отрезаем его.
+		if strings.HasSuffix(line, string(delimiter)) {
+			line = line[:len(line)-1]
+			h.missingFinalDelimiter = false
+		} else if line != "" {
+			h.missingFinalDelimiter = true
+		}
+
+		// Пустой остаток после последнего разделителя не считаем отдельной записью
+		if err == nil || line != "" {
+			h.add(line)
+		}
 
-	for scanner.Scan() {
-		h.lines = append(h.lines, scanner.Text())
+		if err == io.EOF {
+			h.restoreInputOrder()
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// add добавляет строку в FileHolder, при необходимости присваивая ей случайный вес. Если задан --head или --tail,
+// строка попадает в кучу отобранных строк и вытесняет из неё худшую.
+func (h *FileHolder) add(line string) {
+	if h.debug {
+		h.countFallbacks(line)
+	}
+
+	var weight uint64
+	if h.ordering == OrderShuffle {
+		weight = h.rng.Uint64()
+	}
+
+	limit := h.limit()
+	if limit <= 0 {
+		h.lines = append(h.lines, line)
+
+		if h.ordering == OrderShuffle {
+			h.weights = append(h.weights, weight)
+		}
+
+		return
+	}
+
+	// Строка с уже отобранным ключом при -u всё равно не попадёт в вывод
+	key := h.Key(line)
+	if h.unique && h.selectedKeys[key] {
+		return
+	}
+
+	// Добавляем строку в кучу, и если строк стало больше лимита, то выкидываем худшую из них. Это может оказаться и
+	// только что добавленная строка.
+	s := selection{h}
+	heap.Push(s, selectedLine{line: line, weight: weight, sequence: h.added})
+	h.added++
+	h.selectedKeys[key] = true
+
+	if h.Len() > limit {
+		removed := heap.Pop(s).(selectedLine)
+		delete(h.selectedKeys, h.Key(removed.line))
+	}
+}
+
+// countFallbacks учитывает строку в счётчиках для Warnings
+func (h *FileHolder) countFallbacks(line string) {
+	info := h.KeyInfo(line)
+	h.readCount++
+
+	if info.MissingColumn {
+		h.missingColumnCount++
+	}
+
+	if info.NotANumber {
+		h.notANumberCount++
+	}
+}
+
+// restoreInputOrder расставляет отобранные строки кучи в порядке их появления во входных данных, чтобы последующая
+// устойчивая сортировка упорядочила строки с равными ключами так же, как без --head и --tail.
+func (h *FileHolder) restoreInputOrder() {
+	if h.sequence == nil {
+		return
+	}
+
+	sort.Sort(inputOrder{h})
+	h.sequence = nil
+}
+
+// limit возвращает количество строк, которые нужно оставить, или 0, если нужно оставить все строки.
+func (h *FileHolder) limit() int {
+	if h.headLimit > 0 {
+		return h.headLimit
 	}
+
+	return h.tailLimit
 }
 
-// WriteOutput записывает в переданный writer отсортированные данные, находящиеся в FileHolder.
+// WriteOutput записывает в переданный writer отсортированные данные, находящиеся в FileHolder. В режиме --group-by
+// вместо строк записываются результаты агрегации.
 func (h *FileHolder) WriteOutput(writer io.Writer) (n int, err error) {
+	if h.groupBy {
+		return h.writeGroups(writer)
+	}
+
 	uniqueKeys := make(map[any]bool, h.Len())
+	delimiter := []byte{h.delimiter()}
+
+	// Разделитель пишем перед каждой строкой, кроме первой, а не после каждой. Так проще не выводить его после
+	// последней строки, если во входных данных его тоже не было.
+	written := false
 
 	for _, line := range h.lines {
 		k := h.Key(line)
@@ -98,19 +433,211 @@ func (h *FileHolder) WriteOutput(writer io.Writer) (n int, err error) {
 			continue
 		}
 
+		if written {
+			m, err := writer.Write(delimiter)
+			n += m
+			if err != nil {
+				return n, err
+			}
+		}
+
 		// Записываем текущую строку
-		m, err := writer.Write([]byte(line + "\n"))
+		m, err := io.WriteString(writer, line)
 		n += m
 		if err != nil {
 			return n, err
 		}
 
+		// В режиме отладки под строкой показываем, какая её часть использовалась в качестве ключа
+		if h.debug {
+			m, err = io.WriteString(writer, string(delimiter)+h.underline(line))
+			n += m
+			if err != nil {
+				return n, err
+			}
+		}
+
+		written = true
 		uniqueKeys[k] = true
 	}
 
+	// Завершаем вывод разделителем, если им завершались и входные данные
+	if written && !h.missingFinalDelimiter {
+		m, err := writer.Write(delimiter)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+
 	return
 }
 
+// underline возвращает строку, в которой символами "_" отмечена часть line, используемая в качестве ключа. Если
+// ключ пустой или не является числом при -n, вместо подчёркивания в начале ключа выводится пояснение.
+func (h *FileHolder) underline(line string) string {
+	info := h.KeyInfo(line)
+
+	// Отступ повторяет символы табуляции из исходной строки, чтобы подчёркивание оказалось под ключом
+	var sb strings.Builder
+	for _, r := range line[:info.Start] {
+		if r == '\t' {
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+
+	switch {
+	case info.NotANumber:
+		sb.WriteString("^ no number, 0 used")
+	case info.Start == info.End:
+		sb.WriteString("^ no match for key")
+	default:
+		sb.WriteString(strings.Repeat("_", utf8.RuneCountInString(line[info.Start:info.End])))
+	}
+
+	return sb.String()
+}
+
+// Warnings возвращает сводку о прочитанных строках, для которых ключ пришлось получить не так, как было задано
+// параметрами: столбца с нужным индексом нет, или значение не является числом. Строки учитываются во время чтения
+// в режиме отладки, поэтому в сводку попадают и строки, отброшенные --head или --tail.
+func (h *FileHolder) Warnings() []string {
+	warnings := make([]string, 0)
+
+	if h.missingColumnCount > 0 {
+		warnings = append(warnings, fmt.Sprintf(
+			"%d of %d lines have no column %d, whole line used as key",
+			h.missingColumnCount, h.readCount, h.columnIndex,
+		))
+	}
+
+	if h.notANumberCount > 0 {
+		warnings = append(warnings, fmt.Sprintf(
+			"%d of %d lines have non-numeric keys, 0 used as key", h.notANumberCount, h.readCount,
+		))
+	}
+
+	return warnings
+}
+
+// writeGroups объединяет строки с одинаковыми ключами в группы и записывает в writer по одной строке на группу: ключ
+// и значения агрегатов через пробел. Группы выводятся в порядке первого появления ключа в отсортированных данных.
+func (h *FileHolder) writeGroups(writer io.Writer) (n int, err error) {
+	groups := make(map[any]*group)
+	order := make([]*group, 0)
+
+	for _, line := range h.lines {
+		k := h.Key(line)
+
+		g, ok := groups[k]
+		if !ok {
+			g = &group{key: k, min: math.NaN(), max: math.NaN()}
+			groups[k] = g
+			order = append(order, g)
+		}
+
+		g.add(column(line, h.valueColumn))
+	}
+
+	for _, g := range order {
+		fields := make([]string, 0, len(h.aggregates)+1)
+		fields = append(fields, formatValue(g.key))
+
+		for _, a := range h.aggregates {
+			fields = append(fields, g.value(a))
+		}
+
+		m, err := io.WriteString(writer, strings.Join(fields, " ")+string(h.delimiter()))
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return
+}
+
+// group накапливает значения агрегатов для строк с одинаковым ключом.
+type group struct {
+	key   any
+	count int
+
+	// Количество значений, которые удалось преобразовать в число. Только они учитываются в sum, min, max и mean.
+	numbers  int
+	sum      float64
+	min, max float64
+
+	first, last string
+}
+
+// add учитывает в группе очередную строку со значением value.
+func (g *group) add(value string) {
+	if g.count == 0 {
+		g.first = value
+	}
+
+	g.count++
+	g.last = value
+
+	float, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+
+	if g.numbers == 0 || float < g.min {
+		g.min = float
+	}
+
+	if g.numbers == 0 || float > g.max {
+		g.max = float
+	}
+
+	g.numbers++
+	g.sum += float
+}
+
+// value возвращает строковое представление агрегата a для группы.
+func (g *group) value(a Aggregate) string {
+	switch a {
+	case AggregateCount:
+		return strconv.Itoa(g.count)
+	case AggregateSum:
+		return formatValue(g.sum)
+	case AggregateMin:
+		return formatValue(g.min)
+	case AggregateMax:
+		return formatValue(g.max)
+	case AggregateMean:
+		return formatValue(g.sum / float64(g.numbers))
+	case AggregateFirst:
+		return g.first
+	case AggregateLast:
+		return g.last
+	default:
+		panic("unsupported aggregate")
+	}
+}
+
+// formatValue преобразует ключ или числовое значение в строку для вывода.
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		panic("unsupported types")
+	}
+}
+
+// Sort сортирует строки FileHolder. Сортировка устойчивая: строки с равными ключами сохраняют порядок из входных
+// данных, поэтому значения агрегатов first и last при --group-by и вывод --debug не зависят от алгоритма сортировки.
+func (h *FileHolder) Sort() {
+	sort.Stable(h)
+}
+
 func (h *FileHolder) Len() int {
 	return len(h.lines)
 }
@@ -118,6 +645,11 @@ func (h *FileHolder) Len() int {
 // Less сравнивает строки с индексами i и j с учётом заданных параметров сортировки. Возвращается true, если элемент с
 // индексом i должен стоять перед элементом с индексом j.
 func (h *FileHolder) Less(i, j int) bool {
+	// При перемешивании ключи не важны, порядок определяется только случайными весами строк
+	if h.ordering == OrderShuffle {
+		return h.weights[i] < h.weights[j]
+	}
+
 	// Получаем ключи для каждой из строк, которые мы будем непосредственно сравнивать
 	a := h.Key(h.lines[i])
 	b := h.Key(h.lines[j])
@@ -127,6 +659,15 @@ func (h *FileHolder) Less(i, j int) bool {
 		a, b = b, a
 	}
 
+	// При случайной сортировке сначала сравниваем хэши ключей. Равные ключи дают равные хэши, поэтому такие строки
+	// останутся рядом. Если хэши совпали, то для детерминированности сравниваем сами ключи.
+	if h.ordering == OrderByRandomKey {
+		ha, hb := h.keyHash(a), h.keyHash(b)
+		if ha != hb {
+			return ha < hb
+		}
+	}
+
 	// Приводим значения ключей к конкретным типам, чтобы иметь возможность сравнить их через операцию "<"
 	switch a.(type) {
 	case string:
@@ -141,33 +682,172 @@ func (h *FileHolder) Less(i, j int) bool {
 // Swap меняет местами элементы на позициях i и j
 func (h *FileHolder) Swap(i, j int) {
 	h.lines[i], h.lines[j] = h.lines[j], h.lines[i]
+
+	if h.weights != nil {
+		h.weights[i], h.weights[j] = h.weights[j], h.weights[i]
+	}
+
+	if h.sequence != nil {
+		h.sequence[i], h.sequence[j] = h.sequence[j], h.sequence[i]
+	}
+}
+
+// selectedLine - строка вместе со случайным весом и порядковым номером во входных данных, добавляемая в кучу или
+// извлекаемая из неё.
+type selectedLine struct {
+	line     string
+	weight   uint64
+	sequence int
+}
+
+// selection - двоичная куча (heap.Interface) поверх строк FileHolder. В её корне находится худшая из отобранных
+// строк: при --head это последняя строка в порядке устойчивой сортировки, при --tail - первая. Из строк с равными
+// ключами последней считается та, что позже встретилась во входных данных.
+type selection struct {
+	*FileHolder
+}
+
+func (s selection) Less(i, j int) bool {
+	if s.tailLimit > 0 {
+		i, j = j, i
+	}
+
+	if s.FileHolder.Less(j, i) {
+		return true
+	}
+
+	return !s.FileHolder.Less(i, j) && s.sequence[i] > s.sequence[j]
+}
+
+func (s selection) Push(x any) {
+	l := x.(selectedLine)
+	s.lines = append(s.lines, l.line)
+	s.sequence = append(s.sequence, l.sequence)
+
+	if s.weights != nil {
+		s.weights = append(s.weights, l.weight)
+	}
+}
+
+func (s selection) Pop() any {
+	n := len(s.lines) - 1
+	l := selectedLine{line: s.lines[n], sequence: s.sequence[n]}
+	s.lines = s.lines[:n]
+	s.sequence = s.sequence[:n]
+
+	if s.weights != nil {
+		l.weight = s.weights[n]
+		s.weights = s.weights[:n]
+	}
+
+	return l
+}
+
+// inputOrder упорядочивает строки FileHolder по их порядковым номерам во входных данных
+type inputOrder struct {
+	*FileHolder
+}
+
+func (o inputOrder) Less(i, j int) bool {
+	return o.sequence[i] < o.sequence[j]
+}
+
+// keyHash вычисляет хэш ключа, зависящий от seed. Используется для сортировки -R.
+func (h *FileHolder) keyHash(key any) uint64 {
+	hash := fnv.New64a()
+	buf := make([]byte, 8)
+
+	binary.LittleEndian.PutUint64(buf, h.seed)
+	hash.Write(buf)
+
+	switch k := key.(type) {
+	case string:
+		hash.Write([]byte(k))
+	case float64:
+		// -0 и 0 равны как ключи, но различаются в битовом представлении
+		if k == 0 {
+			k = 0
+		}
+
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(k))
+		hash.Write(buf)
+	default:
+		panic("unsupported types")
+	}
+
+	return hash.Sum64()
 }
 
 // Key получает ключ, который будет использоваться непосредственно для сравнения элементов при сортировке, для строки
 // input. Учитывает возможность разбиения на столбцы и использования в качестве ключа числового значения вместо строкового.
 func (h *FileHolder) Key(input string) any {
-	// Если задан определённый индекс столбца, по которому нужно сортировать, разбиваем входную строку на столбцы и
-	// выбираем столбец с нужным индексом. Если индекс находится вне границ массива, игнорируем разбиение по столбцам и
-	// работаем со всей строкой целиком.
-	if h.columnIndex >= 0 {
-		columns := strings.Split(input, " ")
-		if h.columnIndex < len(columns) {
-			input = columns[h.columnIndex]
-		}
+	return h.KeyInfo(input).Key
+}
+
+// KeyInfo описывает ключ строки и то, как он был получен.
+type KeyInfo struct {
+	Key any
+
+	// Границы части строки, из которой получен ключ (в байтах)
+	Start, End int
+
+	// Столбца с заданным индексом в строке нет, поэтому ключ получен из строки целиком
+	MissingColumn bool
+
+	// Требовалось числовое значение, но его не удалось получить, поэтому в качестве ключа использован 0
+	NotANumber bool
+}
+
+// KeyInfo получает ключ для строки input так же, как Key, но дополнительно сообщает, из какой части строки он получен
+// и пришлось ли отступить от заданных параметров.
+func (h *FileHolder) KeyInfo(input string) KeyInfo {
+	// Если задан определённый индекс столбца, по которому нужно сортировать, работаем только с этим столбцом
+	start, end, ok := columnSpan(input, h.columnIndex)
+	info := KeyInfo{
+		Key:           input[start:end],
+		Start:         start,
+		End:           end,
+		MissingColumn: h.columnIndex >= 0 && !ok,
 	}
 
 	// Если требуется использовать числовое значение вместо строкового, то преобразуем входную строку в число. Если
 	// преобразование не удаётся, используем в качестве ключа 0.
 	if h.arithmeticValue {
-		float, err := strconv.ParseFloat(input, 64)
+		float, err := strconv.ParseFloat(input[start:end], 64)
 		if err != nil {
-			return 0.0
+			float = 0.0
+			info.NotANumber = true
 		}
 
-		return float
+		info.Key = float
 	}
 
-	return input
+	return info
+}
+
+// column возвращает столбец с индексом index из строки input. Если индекс отрицательный или находится вне границ
+// массива столбцов, игнорируем разбиение по столбцам и возвращаем всю строку целиком.
+func column(input string, index int) string {
+	start, end, _ := columnSpan(input, index)
+	return input[start:end]
+}
+
+// columnSpan возвращает границы столбца с индексом index в строке input. Если столбца с таким индексом нет,
+// возвращаются границы всей строки и ok = false.
+func columnSpan(input string, index int) (start, end int, ok bool) {
+	if index >= 0 {
+		columns := strings.Split(input, " ")
+		if index < len(columns) {
+			// Начало столбца - суммарная длина предыдущих столбцов вместе с разделителями
+			for _, c := range columns[:index] {
+				start += len(c) + 1
+			}
+
+			return start, start + len(columns[index]), true
+		}
+	}
+
+	return 0, len(input), false
 }
 
 func main() {
@@ -191,12 +871,47 @@ func main() {
 	// Закрываем файл при выходе из программы
 	defer file.Close()
 
+	if *shuffle && *randomSort {
+		fmt.Println("options -R and --shuffle are mutually exclusive")
+		os.Exit(1)
+		return
+	}
+
+	if *headLimit < 0 || *tailLimit < 0 || (*headLimit > 0 && *tailLimit > 0) {
+		fmt.Println("--head and --tail must be positive and cannot be used together")
+		os.Exit(1)
+		return
+	}
+
 	// Инициализируем FileHolder
 	s := NewFileHolder()
-	s.ReadLines(file)
+
+	s.aggregates, err = ParseAggregates(*aggregateList)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+		return
+	}
+
+	// Для случайных стратегий определяем seed заранее, до чтения строк
+	if s.ordering != OrderByKey {
+		s.seed, err = RandomSeed()
+		if err != nil {
+			fmt.Println("unable to get random seed:", err)
+			os.Exit(2)
+			return
+		}
+	}
+
+	err = s.ReadLines(file)
+	if err != nil {
+		fmt.Println("unable to read input:", err)
+		os.Exit(2)
+		return
+	}
 
 	// Осуществляем сортировку
-	sort.Sort(s)
+	s.Sort()
 
 	// По умолчанию выводим в stdout.
 	// Если передано два аргумента, то считаем последний из них названием выходного файла.
@@ -211,6 +926,13 @@ func main() {
 		}
 	}
 
+	// В режиме отладки сообщаем о строках, ключи которых получены не так, как было задано
+	if s.debug {
+		for _, warning := range s.Warnings() {
+			_, _ = fmt.Fprintln(os.Stderr, "sort: warning:", warning)
+		}
+	}
+
 	// Записываем выходные данные
 	_, err = s.WriteOutput(out)
 	if err != nil {
@@ -2,11 +2,15 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
-const input = "5 0\n10 1\n4 2\n3 3\n3 3\n2 4\n2 5"
+const input = "5 0\n10 1\n4 2\n3 3\n3 3\n2 4\n2 5\n"
 
 type testCase struct {
 	input    string
@@ -74,11 +78,297 @@ func TestFileHolder(t *testing.T) {
 	}
 
 	for i, c := range tests {
-		c.holder.ReadLines(bytes.NewReader([]byte(c.input)))
-		sort.Sort(c.holder)
+		err := c.holder.ReadLines(bytes.NewReader([]byte(c.input)))
+		if err != nil {
+			t.Errorf("error in test %d: %s", i, err)
+		}
+
+		c.holder.Sort()
+
+		buf := &bytes.Buffer{}
+		_, err = c.holder.WriteOutput(buf)
+		if err != nil {
+			t.Errorf("error in test %d: %s", i, err)
+		}
+
+		if buf.String() != c.expected {
+			t.Errorf("unexpected value in test %d:\n %s", i, buf.String())
+		}
+	}
+}
+
+func TestFileHolder_Delimiters(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+
+	tests := []testCase{
+		{
+			holder:   &FileHolder{columnIndex: -1},
+			input:    "b\nc\na",
+			expected: "a\nb\nc",
+		},
+		{
+			holder:   &FileHolder{columnIndex: -1},
+			input:    "b\n\na\n",
+			expected: "\na\nb\n",
+		},
+		{
+			holder:   &FileHolder{columnIndex: -1, nullTerminated: true},
+			input:    "./b c\x00./a\nb\x00",
+			expected: "./a\nb\x00./b c\x00",
+		},
+		{
+			holder:   &FileHolder{columnIndex: -1},
+			input:    "b\n" + long + "\na\n",
+			expected: "a\nb\n" + long + "\n",
+		},
+		{
+			holder:   &FileHolder{columnIndex: -1},
+			input:    "",
+			expected: "",
+		},
+	}
+
+	for i, c := range tests {
+		err := c.holder.ReadLines(strings.NewReader(c.input))
+		if err != nil {
+			t.Errorf("error in test %d: %s", i, err)
+		}
+
+		c.holder.Sort()
+
+		buf := &bytes.Buffer{}
+		_, err = c.holder.WriteOutput(buf)
+		if err != nil {
+			t.Errorf("error in test %d: %s", i, err)
+		}
+
+		if buf.String() != c.expected {
+			t.Errorf("unexpected value in test %d:\n %q", i, buf.String())
+		}
+	}
+}
+
+// failingReader отдаёт часть данных, после чего возвращает ошибку
+type failingReader struct {
+	data string
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, errors.New("read failed")
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestFileHolder_ReadLinesError(t *testing.T) {
+	holder := &FileHolder{columnIndex: -1}
+	err := holder.ReadLines(&failingReader{data: "a\nb\n"})
+	if err == nil {
+		t.Errorf("expected read error, got lines %v", holder.lines)
+	}
+}
+
+// sortLines считывает input в holder, сортирует и возвращает выходные строки
+func sortLines(t *testing.T, holder *FileHolder, input string) []string {
+	err := holder.ReadLines(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holder.Sort()
+
+	buf := &bytes.Buffer{}
+	_, err = holder.WriteOutput(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func TestFileHolder_RandomOrdering(t *testing.T) {
+	const data = "a 1\nb 2\na 3\nc 4\nb 5\na 6\nd 7\ne 8\n"
+
+	for _, ordering := range []Ordering{OrderByRandomKey, OrderShuffle} {
+		first := sortLines(t, &FileHolder{columnIndex: 0, ordering: ordering, seed: 42}, data)
+		second := sortLines(t, &FileHolder{columnIndex: 0, ordering: ordering, seed: 42}, data)
+
+		// С одинаковым seed порядок должен воспроизводиться
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("ordering %d is not reproducible: %v != %v", ordering, first, second)
+		}
+
+		// Результат должен быть перестановкой входных строк
+		actual := append([]string{}, first...)
+		expected := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+		sort.Strings(actual)
+		sort.Strings(expected)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("ordering %d lost lines: %v", ordering, first)
+		}
+	}
+
+	// При -R строки с одинаковыми ключами должны идти подряд
+	lines := sortLines(t, &FileHolder{columnIndex: 0, ordering: OrderByRandomKey, seed: 7}, data)
+	seen := make(map[string]bool)
+	for i, line := range lines {
+		key := strings.Split(line, " ")[0]
+		if seen[key] && !strings.HasPrefix(lines[i-1], key+" ") {
+			t.Errorf("lines with key %s are not adjacent: %v", key, lines)
+		}
+
+		seen[key] = true
+	}
+
+	// Разные seed должны давать разные перестановки хотя бы в одном из нескольких случаев
+	base := sortLines(t, &FileHolder{columnIndex: -1, ordering: OrderShuffle, seed: 1}, data)
+	differs := false
+	for s := uint64(2); s < 10 && !differs; s++ {
+		other := sortLines(t, &FileHolder{columnIndex: -1, ordering: OrderShuffle, seed: s}, data)
+		differs = !reflect.DeepEqual(base, other)
+	}
+
+	if !differs {
+		t.Errorf("shuffle does not depend on seed")
+	}
+}
+
+func TestFileHolder_HeadTail(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&sb, "line%d %d\n", i, (i*73)%200)
+	}
+
+	// Много строк с равными ключами: отобранные строки должны идти в том же порядке, что и после полной устойчивой
+	// сортировки
+	var ties strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&ties, "%d line%d\n", (i*7)%5, i)
+	}
+
+	unique := "b\nc\na\nb\nd\na\ne\nc\nf\n"
+
+	tests := []struct {
+		holder FileHolder
+		input  string
+	}{
+		{holder: FileHolder{columnIndex: 1, arithmeticValue: true}, input: sb.String()},
+		{holder: FileHolder{columnIndex: 1, arithmeticValue: true, reverseOrder: true}, input: sb.String()},
+		{holder: FileHolder{columnIndex: 1}, input: sb.String()},
+		{holder: FileHolder{columnIndex: -1, unique: true}, input: unique},
+		{holder: FileHolder{columnIndex: -1, unique: true, reverseOrder: true}, input: unique},
+		{holder: FileHolder{columnIndex: 0, ordering: OrderByRandomKey, seed: 5}, input: sb.String()},
+		{holder: FileHolder{columnIndex: -1, ordering: OrderShuffle, seed: 5}, input: sb.String()},
+		{holder: FileHolder{columnIndex: 0}, input: "a 1\na 2\na 3\nb 0\na 4\na 5\n"},
+		{holder: FileHolder{columnIndex: 0, arithmeticValue: true}, input: ties.String()},
+		{holder: FileHolder{columnIndex: 0, arithmeticValue: true, reverseOrder: true}, input: ties.String()},
+		{holder: FileHolder{columnIndex: 0, unique: true}, input: ties.String()},
+	}
+
+	for i, c := range tests {
+		full := c.holder
+		all := sortLines(t, &full, c.input)
+
+		for _, n := range []int{1, 3, 10, 1000} {
+			expected := all
+			if n < len(all) {
+				expected = all[:n]
+			}
+
+			head := c.holder
+			head.headLimit = n
+			if actual := sortLines(t, &head, c.input); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("unexpected --head %d in test %d: %v (expected %v)", n, i, actual, expected)
+			}
+
+			expected = all
+			if n < len(all) {
+				expected = all[len(all)-n:]
+			}
+
+			tail := c.holder
+			tail.tailLimit = n
+			if actual := sortLines(t, &tail, c.input); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("unexpected --tail %d in test %d: %v (expected %v)", n, i, actual, expected)
+			}
+
+			// В памяти должно оставаться не больше n строк
+			if head.Len() > n || tail.Len() > n {
+				t.Errorf("holder keeps too many lines in test %d: %d, %d", i, head.Len(), tail.Len())
+			}
+		}
+	}
+}
+
+func TestFileHolder_GroupBy(t *testing.T) {
+	const data = "b 2\na 1\nc x\na 3\nb 4\na 5\nd\n"
+
+	// Много строк с равными ключами вперемешку: неустойчивая сортировка переставила бы их и исказила first и last
+	var many strings.Builder
+	for i := 1; i <= 50; i++ {
+		fmt.Fprintf(&many, "%c %d\n", 'a'+rune(i%2), i)
+	}
+
+	tests := []testCase{
+		{
+			holder: &FileHolder{
+				columnIndex: 0,
+				valueColumn: 1,
+				groupBy:     true,
+				aggregates:  []Aggregate{AggregateCount},
+			},
+			input:    data,
+			expected: "a 3\nb 2\nc 1\nd 1\n",
+		},
+		{
+			holder: &FileHolder{
+				columnIndex: 0,
+				valueColumn: 1,
+				groupBy:     true,
+				aggregates: []Aggregate{
+					AggregateSum, AggregateMin, AggregateMax, AggregateMean, AggregateFirst, AggregateLast,
+				},
+			},
+			input:    "a 1\na 3\na 5\nb 2\nb 4\nc x\n",
+			expected: "a 9 1 5 3 1 5\nb 6 2 4 3 2 4\nc 0 NaN NaN NaN x x\n",
+		},
+		{
+			holder: &FileHolder{
+				columnIndex:     1,
+				arithmeticValue: true,
+				reverseOrder:    true,
+				valueColumn:     0,
+				groupBy:         true,
+				aggregates:      []Aggregate{AggregateCount, AggregateFirst},
+			},
+			input:    "x 10\ny 2\nz 10\nw 2.5\n",
+			expected: "10 2 x\n2.5 1 w\n2 1 y\n",
+		},
+		{
+			holder: &FileHolder{
+				columnIndex: 0,
+				valueColumn: 1,
+				groupBy:     true,
+				aggregates:  []Aggregate{AggregateCount, AggregateFirst, AggregateLast},
+			},
+			input:    many.String(),
+			expected: "a 25 2 50\nb 25 1 49\n",
+		},
+	}
+
+	for i, c := range tests {
+		err := c.holder.ReadLines(strings.NewReader(c.input))
+		if err != nil {
+			t.Errorf("error in test %d: %s", i, err)
+		}
+
+		c.holder.Sort()
 
 		buf := &bytes.Buffer{}
-		_, err := c.holder.WriteOutput(buf)
+		_, err = c.holder.WriteOutput(buf)
 		if err != nil {
 			t.Errorf("error in test %d: %s", i, err)
 		}
@@ -87,4 +377,59 @@ func TestFileHolder(t *testing.T) {
 			t.Errorf("unexpected value in test %d:\n %s", i, buf.String())
 		}
 	}
+
+	if _, err := ParseAggregates("count,median"); err == nil {
+		t.Errorf("expected error for unknown aggregate")
+	}
+}
+
+func TestFileHolder_Debug(t *testing.T) {
+	holder := &FileHolder{columnIndex: 1, arithmeticValue: true, debug: true}
+	err := holder.ReadLines(strings.NewReader("b 2\nжук 10\nc\nd x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holder.Sort()
+
+	buf := &bytes.Buffer{}
+	_, err = holder.WriteOutput(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "c\n^ no number, 0 used\nd x\n  ^ no number, 0 used\nb 2\n  _\nжук 10\n    __\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected debug output:\n%s", buf.String())
+	}
+
+	warnings := holder.Warnings()
+	expectedWarnings := []string{
+		"1 of 4 lines have no column 1, whole line used as key",
+		"2 of 4 lines have non-numeric keys, 0 used as key",
+	}
+
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+}
+
+func TestFileHolder_WarningsWithLimit(t *testing.T) {
+	// Строки без числового ключа сортируются первыми и при --tail отбрасываются, но в сводке должны учитываться
+	holder := &FileHolder{columnIndex: 1, arithmeticValue: true, debug: true, tailLimit: 2}
+	lines := sortLines(t, holder, "a 3\nb x\nc\nd 1\ne 2\n")
+
+	if !reflect.DeepEqual(lines, []string{"e 2", "  _", "a 3", "  _"}) {
+		t.Errorf("unexpected output: %q", lines)
+	}
+
+	warnings := holder.Warnings()
+	expectedWarnings := []string{
+		"1 of 5 lines have no column 1, whole line used as key",
+		"2 of 5 lines have non-numeric keys, 0 used as key",
+	}
+
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
 }
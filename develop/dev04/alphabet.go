@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sort"
+	"unicode"
+)
+
+// Alphabet описывает, какие символы слова считаются буквами и какие буквы считаются одинаковыми. От алфавита зависит
+// сигнатура слова, а значит, и то, какие слова будут считаться анаграммами.
+type Alphabet struct {
+	// Name - короткое название алфавита, например "ru"
+	Name string
+
+	// Normalize приводит слово к нормальной форме Unicode до разбора на буквы. Если nil, слово не нормализуется.
+	Normalize Normalizer
+
+	// Fold - правила отождествления букв, применяемые после перевода в нижний регистр. Например, ё -> е.
+	Fold map[rune]rune
+
+	// IsLetter определяет, учитывается ли символ в сигнатуре. Если nil, учитываются все буквы и цифры Unicode.
+	IsLetter func(rune) bool
+}
+
+// Letters возвращает буквы слова в нижнем регистре после нормализации и отождествления. Символы, не являющиеся
+// буквами алфавита (дефисы, апострофы, пробелы), отбрасываются.
+func (a *Alphabet) Letters(word string) []rune {
+	if a.Normalize != nil {
+		word = a.Normalize(word)
+	}
+
+	letters := make([]rune, 0, len(word))
+
+	for _, r := range word {
+		r = unicode.ToLower(r)
+
+		if folded, ok := a.Fold[r]; ok {
+			r = folded
+		}
+
+		if a.IsLetter != nil && !a.IsLetter(r) {
+			continue
+		}
+
+		if a.IsLetter == nil && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+
+		letters = append(letters, r)
+	}
+
+	return letters
+}
+
+// Hash преобразует слово в сигнатуру, которая зависит лишь от количества вхождений каждой из букв алфавита в слово.
+func (a *Alphabet) Hash(word string) Signature {
+	letters := a.Letters(word)
+
+	// Упорядочиваем буквы, чтобы сигнатура не зависела от их порядка в слове
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i] < letters[j]
+	})
+
+	return Signature(letters)
+}
+
+// inRanges возвращает функцию, проверяющую, что символ находится в одном из диапазонов ranges (пары границ
+// включительно) или совпадает с одним из символов extra.
+func inRanges(ranges [][2]rune, extra ...rune) func(rune) bool {
+	return func(r rune) bool {
+		for _, bounds := range ranges {
+			if r >= bounds[0] && r <= bounds[1] {
+				return true
+			}
+		}
+
+		for _, e := range extra {
+			if r == e {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+var (
+	// Russian - русский алфавит. Буквы е и ё считаются одной буквой, всё, что находится вне диапазона а-я,
+	// игнорируется. Нормализация ComposeMarks собирает "й" и "ё", записанные с отдельным комбинируемым знаком.
+	Russian = &Alphabet{
+		Name:      "ru",
+		Normalize: ComposeMarks,
+		Fold:      map[rune]rune{'ё': 'е'},
+		IsLetter:  inRanges([][2]rune{{'а', 'я'}}),
+	}
+
+	// Ukrainian - украинский алфавит: а-я без ё, ъ, ы, э, но с ґ, є, і, ї. Апострофы игнорируются.
+	Ukrainian = &Alphabet{
+		Name:      "uk",
+		Normalize: ComposeMarks,
+		// Диапазон а-я разбит так, чтобы в него не попали ъ, ы и э
+		IsLetter: inRanges([][2]rune{{'а', 'щ'}, {'ь', 'ь'}, {'ю', 'я'}}, 'ґ', 'є', 'і', 'ї'),
+	}
+
+	// English - латинский алфавит. Диакритика отбрасывается ("café" и "face" - анаграммы), лигатуры раскладываются
+	// на отдельные буквы.
+	English = &Alphabet{
+		Name:      "en",
+		Normalize: Chain(DecomposeCompatible, StripMarks),
+		IsLetter:  inRanges([][2]rune{{'a', 'z'}}),
+	}
+
+	// Universal учитывает любые буквы и цифры Unicode без отождествления. Совместимые формы приводятся к обычным
+	// только для символов из таблиц ComposeCompatible, остальные символы сравниваются как есть: например, греческая
+	// "ά" и "α" с отдельным комбинируемым знаком ударения считаются разными.
+	Universal = &Alphabet{
+		Name:      "any",
+		Normalize: ComposeCompatible,
+	}
+
+	// Alphabets - все встроенные алфавиты по их названиям
+	Alphabets = map[string]*Alphabet{
+		Russian.Name:   Russian,
+		Ukrainian.Name: Ukrainian,
+		English.Name:   English,
+		Universal.Name: Universal,
+	}
+)
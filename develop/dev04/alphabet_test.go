@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizers(t *testing.T) {
+	tests := []struct {
+		normalizer Normalizer
+		input      string
+		expected   string
+	}{
+		{DecomposeMarks, "\u0439", "\u0438\u0306"},
+		{DecomposeMarks, "\u1EC7", "e\u0323\u0302"},
+		{DecomposeMarks, "e\u0302\u0323", "e\u0323\u0302"},
+		{ComposeMarks, "\u0438\u0306", "\u0439"},
+		{ComposeMarks, "e\u0302\u0323", "\u1EC7"},
+		{ComposeMarks, "\u0435\u0308\u0436", "\u0451\u0436"},
+		{DecomposeCompatible, "\uFB01anc\u00E9", "fiance\u0301"},
+		{ComposeCompatible, "\uFF21\uFF22\uFF23\u00B2", "ABC2"},
+		{Chain(DecomposeCompatible, StripMarks), "\u00C5ngstr\u00F6m", "Angstrom"},
+		// Символы вне таблиц остаются без изменений
+		{DecomposeMarks, "\u03AC\u01CD", "\u03AC\u01CD"},
+		{ComposeCompatible, "\u03B1\u0301\u3392", "\u03B1\u0301\u3392"},
+	}
+
+	for _, c := range tests {
+		if actual := c.normalizer(c.input); actual != c.expected {
+			t.Errorf("unexpected result for %q: %q (expected %q)", c.input, actual, c.expected)
+		}
+	}
+}
+
+func TestAlphabet_GroupAnagrams(t *testing.T) {
+	tests := []struct {
+		alphabet *Alphabet
+		input    []string
+		expected map[string][]string
+	}{
+		{
+			alphabet: Russian,
+			input:    []string{"пятак", "пятка", "ёлка", "ке\u0308ла", "йод", "ди\u0306о"},
+			expected: map[string][]string{
+				"пятак": {"пятак", "пятка"},
+				"ёлка":  {"ке\u0308ла", "ёлка"},
+				"йод":   {"ди\u0306о", "йод"},
+			},
+		},
+		{
+			alphabet: Ukrainian,
+			input:    []string{"сіль", "ліс", "сил", "їжак", "жакї", "м'ята", "тяма"},
+			expected: map[string][]string{
+				"їжак":  {"жакї", "їжак"},
+				"м'ята": {"м'ята", "тяма"},
+			},
+		},
+		{
+			alphabet: English,
+			input:    []string{"listen", "Silent", "café", "face", "ﬁle", "lief", "well-done", "nodewell"},
+			expected: map[string][]string{
+				"listen":    {"Silent", "listen"},
+				"café":      {"café", "face"},
+				"ﬁle":       {"lief", "ﬁle"},
+				"well-done": {"nodewell", "well-done"},
+			},
+		},
+		{
+			alphabet: Universal,
+			input:    []string{"abc123", "321cba", "пятак", "тяпка", "ліс", "сіл", "лис"},
+			expected: map[string][]string{
+				"abc123": {"321cba", "abc123"},
+				"пятак":  {"пятак", "тяпка"},
+				"ліс":    {"ліс", "сіл"},
+			},
+		},
+	}
+
+	for _, c := range tests {
+		groups := c.alphabet.GroupAnagrams(c.input)
+		if !reflect.DeepEqual(*groups, c.expected) {
+			t.Errorf("unexpected result for alphabet %s: %v (expected %v)", c.alphabet.Name, *groups, c.expected)
+		}
+	}
+}
+
+func TestAlphabet_GroupAnagramsMixedScript(t *testing.T) {
+	// Латинская буква U+0061 в конце слова не входит в украинский алфавит и пропускается, поэтому слово попадает
+	// в группу "мят", а не "м'ята"
+	groups := Ukrainian.GroupAnagrams([]string{"м'ята", "мят", "тям\u0061"})
+	expected := map[string][]string{
+		"мят": {"мят", "тям\u0061"},
+	}
+
+	if !reflect.DeepEqual(*groups, expected) {
+		t.Errorf("unexpected result for mixed script: %v (expected %v)", *groups, expected)
+	}
+}
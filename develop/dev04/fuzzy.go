@@ -0,0 +1,196 @@
+package main
+
+import (
+	"sort"
+)
+
+// LetterCount - количество вхождений одной буквы
+type LetterCount struct {
+	Letter rune
+	Count  int
+}
+
+// CountVector - вектор количества букв слова, упорядоченный по буквам. Это то же представление, из которого строится
+// сигнатура, только в сжатом виде: каждая буква записана один раз вместе со своим количеством.
+type CountVector []LetterCount
+
+// Counts возвращает вектор количества букв для сигнатуры
+func (s Signature) Counts() CountVector {
+	result := make(CountVector, 0)
+
+	for _, r := range s {
+		if n := len(result); n > 0 && result[n-1].Letter == r {
+			result[n-1].Count++
+			continue
+		}
+
+		result = append(result, LetterCount{Letter: r, Count: 1})
+	}
+
+	return result
+}
+
+// Distance возвращает минимальное количество вставок, удалений и замен букв, превращающее набор букв v в набор букв u
+// (порядок букв не важен). Лишние буквы одного набора можно заменить на недостающие, поэтому расстояние равно
+// наибольшему из количества лишних и количества недостающих букв.
+func (v CountVector) Distance(u CountVector) int {
+	surplus, deficit := 0, 0
+
+	i, j := 0, 0
+	for i < len(v) || j < len(u) {
+		switch {
+		case j == len(u) || (i < len(v) && v[i].Letter < u[j].Letter):
+			surplus += v[i].Count
+			i++
+		case i == len(v) || u[j].Letter < v[i].Letter:
+			deficit += u[j].Count
+			j++
+		default:
+			if d := v[i].Count - u[j].Count; d > 0 {
+				surplus += d
+			} else {
+				deficit -= d
+			}
+
+			i++
+			j++
+		}
+	}
+
+	if surplus > deficit {
+		return surplus
+	}
+
+	return deficit
+}
+
+// NearAnagram - слово кластера вместе с расстоянием от ключа кластера
+type NearAnagram struct {
+	Word     string `json:"word"`
+	Distance int    `json:"distance"`
+}
+
+// Cluster - группа слов, наборы букв которых отличаются от набора букв ключа не больше чем на k правок
+type Cluster struct {
+	// Key - первое встретившееся в словаре слово кластера
+	Key string `json:"key"`
+
+	// Words - слова кластера, упорядоченные по расстоянию от ключа, затем по алфавиту. Ключ тоже входит в список.
+	Words []NearAnagram `json:"words"`
+}
+
+// NearAnagrams группирует слова, наборы букв которых отличаются не больше чем на k вставок, удалений или замен.
+// Например, при k = 1 "пятак" и "пятаки" попадают в один кластер. Точные анаграммы всегда находятся в одном кластере
+// с расстоянием 0.
+//
+// Кластеры строятся жадно: слова перебираются в порядке словаря, и каждое ещё не распределённое слово становится
+// ключом нового кластера, в который попадают все нераспределённые слова на расстоянии не больше k от него. Кластеры
+// из одного слова в результат не попадают.
+func (a *Alphabet) NearAnagrams(words []string, k int) []*Cluster {
+	// Работаем с уникальными сигнатурами, а не со словами: точные анаграммы обрабатываются вместе
+	groups := a.Groups(words)
+	vectors := make([]CountVector, len(groups))
+	for i, group := range groups {
+		vectors[i] = group.Signature.Counts()
+	}
+
+	// Если два набора букв отличаются не больше чем на k правок, то их общая часть получается из каждого из них
+	// удалением не больше k букв. Поэтому кандидатов в кластер ищем по общим наборам, полученным удалением до k букв.
+	neighbours := make(map[Signature][]int)
+	for i, group := range groups {
+		for _, reduced := range deletions(group.Signature, k) {
+			neighbours[reduced] = append(neighbours[reduced], i)
+		}
+	}
+
+	assigned := make([]bool, len(groups))
+	result := make([]*Cluster, 0)
+
+	for i, group := range groups {
+		if assigned[i] {
+			continue
+		}
+
+		assigned[i] = true
+		members := make([]int, 0)
+
+		for _, reduced := range deletions(group.Signature, k) {
+			for _, j := range neighbours[reduced] {
+				if !assigned[j] && vectors[i].Distance(vectors[j]) <= k {
+					assigned[j] = true
+					members = append(members, j)
+				}
+			}
+		}
+
+		cluster := &Cluster{Key: group.Key}
+		for _, word := range group.Words {
+			cluster.Words = append(cluster.Words, NearAnagram{Word: word})
+		}
+
+		for _, j := range members {
+			distance := vectors[i].Distance(vectors[j])
+			for _, word := range groups[j].Words {
+				cluster.Words = append(cluster.Words, NearAnagram{Word: word, Distance: distance})
+			}
+		}
+
+		if len(cluster.Words) < 2 {
+			continue
+		}
+
+		sort.SliceStable(cluster.Words, func(x, y int) bool {
+			if cluster.Words[x].Distance != cluster.Words[y].Distance {
+				return cluster.Words[x].Distance < cluster.Words[y].Distance
+			}
+
+			return cluster.Words[x].Word < cluster.Words[y].Word
+		})
+
+		result = append(result, cluster)
+	}
+
+	return result
+}
+
+// deletions возвращает все различные сигнатуры, получаемые из signature удалением не больше k букв, включая саму
+// signature.
+func deletions(signature Signature, k int) []Signature {
+	seen := map[Signature]bool{signature: true}
+	current := []Signature{signature}
+
+	for step := 0; step < k; step++ {
+		next := make([]Signature, 0)
+
+		for _, s := range current {
+			letters := []rune(s)
+
+			for i := range letters {
+				// Удаление любой из одинаковых букв даёт одну и ту же сигнатуру
+				if i > 0 && letters[i] == letters[i-1] {
+					continue
+				}
+
+				reduced := Signature(string(letters[:i]) + string(letters[i+1:]))
+				if !seen[reduced] {
+					seen[reduced] = true
+					next = append(next, reduced)
+				}
+			}
+		}
+
+		current = next
+	}
+
+	result := make([]Signature, 0, len(seen))
+	for s := range seen {
+		result = append(result, s)
+	}
+
+	// Порядок обхода мапы случаен, а от порядка кандидатов зависит состав кластеров
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+
+	return result
+}
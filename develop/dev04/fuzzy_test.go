@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountVector_Distance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"пятак", "тяпка", 0},
+		{"пятак", "пятаки", 1},
+		{"пятак", "пятно", 2},
+		{"кот", "ток", 0},
+		{"кот", "кит", 1},
+		{"кот", "", 3},
+		{"аааб", "ббв", 3},
+	}
+
+	for _, c := range tests {
+		a, b := Hash(c.a).Counts(), Hash(c.b).Counts()
+		if d := a.Distance(b); d != c.expected || b.Distance(a) != d {
+			t.Errorf("unexpected distance between %s and %s: %d (expected %d)", c.a, c.b, d, c.expected)
+		}
+	}
+}
+
+func TestNearAnagrams(t *testing.T) {
+	words := []string{"пятак", "пятаки", "кот", "тяпка", "ток", "кит", "арбуз", "пятно", "кота"}
+
+	expected := []*Cluster{
+		{
+			Key: "пятак",
+			Words: []NearAnagram{
+				{Word: "пятак"}, {Word: "тяпка"}, {Word: "пятаки", Distance: 1},
+			},
+		},
+		{
+			Key: "кот",
+			Words: []NearAnagram{
+				{Word: "кот"}, {Word: "ток"}, {Word: "кит", Distance: 1}, {Word: "кота", Distance: 1},
+			},
+		},
+	}
+
+	clusters := Russian.NearAnagrams(words, 1)
+	if !reflect.DeepEqual(clusters, expected) {
+		t.Errorf("unexpected clusters: %+v", clusters)
+	}
+
+	// При k = 2 к пятаку добавляются пятно и кота: пятак встречается в словаре раньше кота, поэтому забирает его первым
+	clusters = Russian.NearAnagrams(words, 2)
+	if len(clusters) != 2 || !reflect.DeepEqual(clusters[0].Words[3:], []NearAnagram{{"кота", 2}, {"пятно", 2}}) {
+		t.Errorf("unexpected clusters for k = 2: %+v", clusters)
+	}
+}
+
+func TestNearAnagrams_MatchesBruteForce(t *testing.T) {
+	words := generateDictionary(3000)
+	clusters := Russian.NearAnagrams(words, 1)
+
+	// Каждое слово кластера должно находиться на заявленном расстоянии от ключа, и это расстояние не больше k
+	for _, cluster := range clusters {
+		key := Hash(cluster.Key).Counts()
+		for _, word := range cluster.Words {
+			d := key.Distance(Hash(word.Word).Counts())
+			if d != word.Distance || d > 1 {
+				t.Errorf("word %s in cluster %s has distance %d (reported %d)", word.Word, cluster.Key, d, word.Distance)
+			}
+		}
+	}
+}
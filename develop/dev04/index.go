@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// indexMagic - сигнатура файла индекса, за которой следует номер версии формата
+const (
+	indexMagic   = "ANAG"
+	indexVersion = 1
+
+	// Максимальная длина слова в файле индекса. Большие значения считаются признаком повреждённого файла.
+	maxIndexStringLength = 1 << 20
+)
+
+// AnagramIndex хранит группы анаграмм и позволяет изменять их по одному слову, не пересчитывая группировку всего
+// словаря. Ключом группы, как и в GroupAnagrams, считается первое добавленное в индекс слово группы, если оно не было
+// удалено. Повторное добавление уже имеющегося слова ничего не меняет.
+type AnagramIndex struct {
+	alphabet *Alphabet
+	lock     *sync.RWMutex
+
+	// Сигнатура -> группа слов с этой сигнатурой
+	groups map[Signature]*indexGroup
+
+	// Слово -> порядковый номер его добавления в индекс
+	words map[string]uint64
+
+	// Порядковый номер, который получит следующее добавленное слово
+	next uint64
+}
+
+// indexGroup хранит слова одной группы в порядке их добавления в индекс
+type indexGroup struct {
+	words []string
+}
+
+// NewAnagramIndex создаёт пустой индекс, определяющий сигнатуры слов по правилам алфавита alphabet.
+func NewAnagramIndex(alphabet *Alphabet) *AnagramIndex {
+	return &AnagramIndex{
+		alphabet: alphabet,
+		lock:     &sync.RWMutex{},
+		groups:   make(map[Signature]*indexGroup),
+		words:    make(map[string]uint64),
+	}
+}
+
+// Clone возвращает независимую копию индекса. Изменения копии не видны читателям исходного индекса.
+func (x *AnagramIndex) Clone() *AnagramIndex {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+
+	clone := NewAnagramIndex(x.alphabet)
+	clone.next = x.next
+
+	for word, n := range x.words {
+		clone.words[word] = n
+	}
+
+	for h, group := range x.groups {
+		clone.groups[h] = &indexGroup{words: append([]string(nil), group.words...)}
+	}
+
+	return clone
+}
+
+// Len возвращает количество слов в индексе
+func (x *AnagramIndex) Len() int {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+
+	return len(x.words)
+}
+
+// Add добавляет слово в индекс. Возвращает false, если слово уже было в индексе.
+func (x *AnagramIndex) Add(word string) bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	if _, ok := x.words[word]; ok {
+		return false
+	}
+
+	x.words[word] = x.next
+	x.next++
+
+	// Новое слово добавлено позже всех остальных, поэтому просто дописываем его в конец группы
+	h := x.alphabet.Hash(word)
+	group, ok := x.groups[h]
+	if !ok {
+		group = &indexGroup{}
+		x.groups[h] = group
+	}
+
+	group.words = append(group.words, word)
+	return true
+}
+
+// Remove удаляет слово из индекса. Если это было первое слово группы, ключом группы становится следующее по порядку
+// добавления слово. Возвращает false, если слова не было в индексе.
+func (x *AnagramIndex) Remove(word string) bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	if _, ok := x.words[word]; !ok {
+		return false
+	}
+
+	delete(x.words, word)
+
+	h := x.alphabet.Hash(word)
+	group := x.groups[h]
+
+	for i, w := range group.words {
+		if w == word {
+			group.words = append(group.words[:i], group.words[i+1:]...)
+			break
+		}
+	}
+
+	if len(group.words) == 0 {
+		delete(x.groups, h)
+	}
+
+	return true
+}
+
+// Lookup возвращает группу анаграмм слова word. Само слово не обязано находиться в индексе. Если в индексе нет ни
+// одного слова с такой же сигнатурой, возвращается false.
+func (x *AnagramIndex) Lookup(word string) (*Group, bool) {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+
+	h := x.alphabet.Hash(word)
+	group, ok := x.groups[h]
+	if !ok {
+		return nil, false
+	}
+
+	return group.export(h), true
+}
+
+// export преобразует группу индекса в Group с отсортированными словами
+func (g *indexGroup) export(h Signature) *Group {
+	words := make(WordContainer, len(g.words))
+	copy(words, g.words)
+	sort.Sort(words)
+
+	return &Group{Key: g.words[0], Signature: h, Words: words}
+}
+
+// Groups возвращает группы, в которых не меньше minSize слов, в порядке добавления их ключей в индекс.
+func (x *AnagramIndex) Groups(minSize int) []*Group {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+
+	result := make([]*Group, 0)
+	for h, group := range x.groups {
+		if len(group.words) >= minSize {
+			result = append(result, group.export(h))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return x.words[result[i].Key] < x.words[result[j].Key]
+	})
+
+	return result
+}
+
+// GroupAnagrams возвращает группы анаграмм из индекса в том же виде, что и функция GroupAnagrams: ключ - первое
+// добавленное слово группы, значение - отсортированные слова, группы из одного слова не попадают в результат.
+func (x *AnagramIndex) GroupAnagrams() *map[string][]string {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+
+	result := make(map[string][]string)
+	for h, group := range x.groups {
+		if len(group.words) < 2 {
+			continue
+		}
+
+		exported := group.export(h)
+		result[exported.Key] = exported.Words
+	}
+
+	return &result
+}
+
+// Save записывает индекс в writer в компактном двоичном формате:
+//   - "ANAG" и версия формата (1 байт)
+//   - название алфавита: длина (uvarint) и байты
+//   - количество слов (uvarint)
+//   - слова в порядке добавления, каждое - длина (uvarint) и байты
+//
+// Группы не сохраняются, поскольку однозначно восстанавливаются по словам при загрузке, а порядок слов сохраняет
+// ключи групп.
+func (x *AnagramIndex) Save(writer io.Writer) error {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+
+	// Восстанавливаем порядок добавления слов
+	words := make([]string, 0, len(x.words))
+	for word := range x.words {
+		words = append(words, word)
+	}
+
+	sort.Slice(words, func(i, j int) bool {
+		return x.words[words[i]] < x.words[words[j]]
+	})
+
+	w := bufio.NewWriter(writer)
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	writeString := func(s string) {
+		n := binary.PutUvarint(buf, uint64(len(s)))
+		_, _ = w.Write(buf[:n])
+		_, _ = w.WriteString(s)
+	}
+
+	_, _ = w.WriteString(indexMagic)
+	_ = w.WriteByte(indexVersion)
+	writeString(x.alphabet.Name)
+
+	n := binary.PutUvarint(buf, uint64(len(words)))
+	_, _ = w.Write(buf[:n])
+
+	for _, word := range words {
+		writeString(word)
+	}
+
+	// bufio.Writer запоминает первую ошибку записи и возвращает её из Flush
+	return w.Flush()
+}
+
+// LoadAnagramIndex загружает индекс, сохранённый методом Save. Индекс должен был быть построен по тому же алфавиту.
+func LoadAnagramIndex(reader io.Reader, alphabet *Alphabet) (*AnagramIndex, error) {
+	r := bufio.NewReader(reader)
+
+	header := make([]byte, len(indexMagic)+1)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read index header: %w", err)
+	}
+
+	if string(header[:len(indexMagic)]) != indexMagic {
+		return nil, errors.New("not an anagram index")
+	}
+
+	if header[len(indexMagic)] != indexVersion {
+		return nil, fmt.Errorf("unsupported index version %d", header[len(indexMagic)])
+	}
+
+	readString := func() (string, error) {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return "", err
+		}
+
+		// Не выделяем память под заведомо неверную длину из повреждённого файла
+		if length > maxIndexStringLength {
+			return "", fmt.Errorf("invalid string length %d", length)
+		}
+
+		s := make([]byte, length)
+		_, err = io.ReadFull(r, s)
+		return string(s), err
+	}
+
+	name, err := readString()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read alphabet name: %w", err)
+	}
+
+	if name != alphabet.Name {
+		return nil, fmt.Errorf("index was built for alphabet \"%s\", not \"%s\"", name, alphabet.Name)
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read word count: %w", err)
+	}
+
+	x := NewAnagramIndex(alphabet)
+	for i := uint64(0); i < count; i++ {
+		word, err := readString()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read word %d: %w", i, err)
+		}
+
+		x.Add(word)
+	}
+
+	return x, nil
+}
+
+// OpenAnagramIndex загружает индекс из файла path. Если файла нет, индекс строится из слов, которые возвращает build,
+// и сохраняется в path, чтобы при следующем запуске не читать словарь заново. Второе возвращаемое значение сообщает,
+// был ли индекс загружен из файла.
+func OpenAnagramIndex(path string, alphabet *Alphabet, build func() ([]string, error)) (*AnagramIndex, bool, error) {
+	file, err := os.Open(path)
+	if err == nil {
+		defer file.Close()
+
+		x, err := LoadAnagramIndex(file, alphabet)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", path, err)
+		}
+
+		return x, true, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, err
+	}
+
+	words, err := build()
+	if err != nil {
+		return nil, false, err
+	}
+
+	x := NewAnagramIndex(alphabet)
+	for _, word := range words {
+		x.Add(word)
+	}
+
+	return x, false, x.SaveFile(path)
+}
+
+// SaveFile сохраняет индекс в файл path. Индекс сначала записывается во временный файл в том же каталоге, который
+// затем переименовывается, поэтому прерванная запись не оставляет повреждённый файл индекса.
+func (x *AnagramIndex) SaveFile(path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	// CreateTemp создаёт файл с правами 0600, а индекс - обычный файл данных, как и словарь
+	err = file.Chmod(0o644)
+	if err == nil {
+		err = x.Save(file)
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+
+	return err
+}
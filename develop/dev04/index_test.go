@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAnagramIndex(t *testing.T) {
+	x := NewAnagramIndex(Russian)
+	for _, word := range []string{"пятак", "листок", "пятка", "слиток", "тяпка", "арбуз"} {
+		x.Add(word)
+	}
+
+	if x.Add("пятка") {
+		t.Errorf("duplicate word was added")
+	}
+
+	group, ok := x.Lookup("капят")
+	if !ok || group.Key != "пятак" || !reflect.DeepEqual([]string(group.Words), []string{"пятак", "пятка", "тяпка"}) {
+		t.Errorf("unexpected lookup result: %v", group)
+	}
+
+	// После удаления ключа ключом становится следующее по порядку добавления слово
+	if !x.Remove("пятак") || x.Remove("пятак") {
+		t.Errorf("unexpected remove result")
+	}
+
+	group, ok = x.Lookup("тяпка")
+	if !ok || group.Key != "пятка" || !reflect.DeepEqual([]string(group.Words), []string{"пятка", "тяпка"}) {
+		t.Errorf("unexpected lookup result after remove: %v", group)
+	}
+
+	// Слово, добавленное заново, оказывается в конце группы и ключом не становится
+	x.Add("пятак")
+	group, _ = x.Lookup("пятак")
+	if group.Key != "пятка" {
+		t.Errorf("unexpected key after re-adding: %s", group.Key)
+	}
+
+	x.Remove("арбуз")
+	if _, ok = x.Lookup("арбуз"); ok {
+		t.Errorf("empty group is still present")
+	}
+}
+
+func TestAnagramIndex_MatchesGroupAnagrams(t *testing.T) {
+	// Словарь без повторов, поскольку индекс хранит каждое слово один раз
+	words := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, word := range generateDictionary(20000) {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+
+	x := NewAnagramIndex(Russian)
+	for _, word := range words {
+		x.Add(word)
+	}
+
+	if !reflect.DeepEqual(*x.GroupAnagrams(), *GroupAnagrams(words)) {
+		t.Errorf("index groups differ from GroupAnagrams")
+	}
+}
+
+func TestAnagramIndex_SaveLoad(t *testing.T) {
+	x := NewAnagramIndex(Russian)
+	for _, word := range []string{"пятак", "листок", "пятка", "слиток", "тяпка", "столик"} {
+		x.Add(word)
+	}
+
+	x.Remove("пятак")
+	x.Add("пятак")
+
+	buf := &bytes.Buffer{}
+	err := x.Save(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadAnagramIndex(bytes.NewReader(buf.Bytes()), Russian)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(*loaded.GroupAnagrams(), *x.GroupAnagrams()) {
+		t.Errorf("loaded index differs: %v (expected %v)", *loaded.GroupAnagrams(), *x.GroupAnagrams())
+	}
+
+	if _, err = LoadAnagramIndex(bytes.NewReader(buf.Bytes()), English); err == nil {
+		t.Errorf("expected error for another alphabet")
+	}
+
+	if _, err = LoadAnagramIndex(bytes.NewReader(buf.Bytes()[:buf.Len()-3]), Russian); err == nil {
+		t.Errorf("expected error for truncated index")
+	}
+
+	if _, err = LoadAnagramIndex(bytes.NewReader([]byte("GARBAGE")), Russian); err == nil {
+		t.Errorf("expected error for invalid header")
+	}
+}
+
+func TestOpenAnagramIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.idx")
+	words := []string{"пятак", "листок", "пятка", "слиток"}
+
+	builds := 0
+	build := func() ([]string, error) {
+		builds++
+		return words, nil
+	}
+
+	// Первый запуск строит индекс по словарю и сохраняет его, второй - загружает из файла, не читая словарь
+	built, loaded, err := OpenAnagramIndex(path, Russian, build)
+	if err != nil || loaded || builds != 1 {
+		t.Fatalf("unexpected first open: loaded %v, %d builds, %v", loaded, builds, err)
+	}
+
+	reopened, loaded, err := OpenAnagramIndex(path, Russian, build)
+	if err != nil || !loaded || builds != 1 {
+		t.Fatalf("unexpected second open: loaded %v, %d builds, %v", loaded, builds, err)
+	}
+
+	if !reflect.DeepEqual(*reopened.GroupAnagrams(), *built.GroupAnagrams()) {
+		t.Errorf("loaded index differs: %v (expected %v)", *reopened.GroupAnagrams(), *built.GroupAnagrams())
+	}
+
+	if _, _, err = OpenAnagramIndex(path, English, build); err == nil || builds != 1 {
+		t.Errorf("expected error for index of another alphabet, got %v", err)
+	}
+
+	failing := func() ([]string, error) {
+		return nil, errors.New("no dictionary")
+	}
+
+	other := filepath.Join(t.TempDir(), "other.idx")
+	if _, _, err = OpenAnagramIndex(other, Russian, failing); err == nil {
+		t.Errorf("expected build error")
+	}
+
+	if _, err = os.Stat(other); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("index saved after failed build: %v", err)
+	}
+}
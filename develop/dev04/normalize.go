@@ -0,0 +1,325 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Normalizer приводит слово к некоторой нормальной форме перед тем, как разбить его на буквы.
+//
+// Пакет golang.org/x/text/unicode/norm недоступен в рамках задания, поэтому ниже вместо нормальных форм Unicode
+// реализованы их ограниченные аналоги на небольших таблицах. Они приводят к NFD, NFC, NFKD и NFKC только символы
+// из таблиц, все остальные символы остаются без изменений:
+//   - составные буквы с диакритикой U+00C0-U+017E (Latin-1 Supplement, Latin Extended-A), U+1E00-U+1EF9
+//     (Latin Extended Additional) и U+0400-U+04F9 (Cyrillic), а также комбинируемые знаки, из которых они
+//     собираются (см. decompositions и combiningClasses);
+//   - для совместимых форм дополнительно надстрочные и подстрочные цифры U+00B2, U+00B3, U+00B9, U+2070-U+2089,
+//     лигатуры U+FB00-U+FB06 и полноширинные символы ASCII U+FF01-U+FF5E (см. compatibilityDecompositions).
+//
+// Греческий, Latin Extended-B, хангыль, CJK и прочие символы вне таблиц не раскладываются и не собираются. Если нужна
+// полная поддержка Unicode, в Alphabet можно передать, например, norm.NFKD.String.
+type Normalizer func(string) string
+
+// DecomposeMarks раскладывает составные символы из таблиц на базовый символ и комбинируемые знаки (аналог NFD).
+func DecomposeMarks(s string) string {
+	return string(decompose(s, false))
+}
+
+// DecomposeCompatible раскладывает составные символы так же, как DecomposeMarks, но дополнительно заменяет символы
+// их совместимыми эквивалентами: лигатуры - отдельными буквами, полноширинные формы - обычными (аналог NFKD).
+func DecomposeCompatible(s string) string {
+	return string(decompose(s, true))
+}
+
+// ComposeMarks раскладывает символы, а затем снова объединяет базовые символы с комбинируемыми знаками, если
+// составной символ есть в таблицах (аналог NFC). Например, "и" с последующим знаком бреве превращается в "й".
+func ComposeMarks(s string) string {
+	return string(compose(decompose(s, false)))
+}
+
+// ComposeCompatible - совместимая декомпозиция с последующей композицией по таблицам (аналог NFKC).
+func ComposeCompatible(s string) string {
+	return string(compose(decompose(s, true)))
+}
+
+// StripMarks удаляет из строки все комбинируемые знаки. Вместе с DecomposeMarks или DecomposeCompatible позволяет
+// избавиться от диакритики: "café" превращается в "cafe".
+func StripMarks(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+
+		return r
+	}, s)
+}
+
+// Chain объединяет несколько нормализаторов в один, применяя их по порядку.
+func Chain(normalizers ...Normalizer) Normalizer {
+	return func(s string) string {
+		for _, n := range normalizers {
+			s = n(s)
+		}
+
+		return s
+	}
+}
+
+// decompose рекурсивно раскладывает символы строки по таблицам и упорядочивает комбинируемые знаки по их классам.
+func decompose(s string, compatibility bool) []rune {
+	result := make([]rune, 0, len(s))
+
+	var appendDecomposed func(r rune)
+	appendDecomposed = func(r rune) {
+		if compatibility {
+			if d, ok := compatibilityDecompositions[r]; ok {
+				for _, c := range d {
+					appendDecomposed(c)
+				}
+
+				return
+			}
+		}
+
+		if d, ok := decompositions[r]; ok {
+			appendDecomposed(d[0])
+			appendDecomposed(d[1])
+			return
+		}
+
+		result = append(result, r)
+	}
+
+	for _, r := range s {
+		appendDecomposed(r)
+	}
+
+	// Каноническое упорядочивание: внутри каждой последовательности комбинируемых знаков знаки сортируются по классу,
+	// чтобы, например, "ệ" не зависела от порядка, в котором были записаны точка снизу и циркумфлекс.
+	for i := 0; i < len(result); {
+		if combiningClasses[result[i]] == 0 {
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(result) && combiningClasses[result[j]] != 0 {
+			j++
+		}
+
+		marks := result[i:j]
+		sort.SliceStable(marks, func(a, b int) bool {
+			return combiningClasses[marks[a]] < combiningClasses[marks[b]]
+		})
+
+		i = j
+	}
+
+	return result
+}
+
+// compositions - таблица, обратная decompositions: пара "базовый символ + знак" -> составной символ
+var compositions = make(map[[2]rune]rune, len(decompositions))
+
+func init() {
+	for composed, pair := range decompositions {
+		compositions[pair] = composed
+	}
+}
+
+// compose объединяет базовые символы с последующими комбинируемыми знаками, если для пары есть составной символ.
+// Знак нельзя объединить с базовым символом, если между ними находится знак того же или большего класса.
+func compose(runes []rune) []rune {
+	if len(runes) == 0 {
+		return runes
+	}
+
+	result := runes[:1]
+	starter := 0
+	lastClass := combiningClasses[runes[0]]
+	if lastClass != 0 {
+		starter = -1
+	}
+
+	for _, r := range runes[1:] {
+		class := combiningClasses[r]
+
+		// Знак не заблокирован, если он идёт сразу за базовым символом или если все знаки между ними имеют меньший
+		// класс
+		blocked := lastClass != 0 && lastClass >= class
+		if len(result)-1 == starter {
+			blocked = false
+		}
+
+		if starter >= 0 && !blocked {
+			if composed, ok := compositions[[2]rune{result[starter], r}]; ok {
+				result[starter] = composed
+				continue
+			}
+		}
+
+		if class == 0 {
+			starter = len(result)
+		}
+
+		lastClass = class
+		result = append(result, r)
+	}
+
+	return result
+}
+
+// decompositions - канонические разложения символов на базовый символ и комбинируемый знак. Таблица получена
+// из UnicodeData для диапазонов Latin-1 Supplement, Latin Extended-A, Latin Extended Additional и Cyrillic.
+var decompositions = map[rune][2]rune{
+	0x00C0: {0x0041, 0x0300}, 0x00C1: {0x0041, 0x0301}, 0x00C2: {0x0041, 0x0302}, 0x00C3: {0x0041, 0x0303},
+	0x00C4: {0x0041, 0x0308}, 0x00C5: {0x0041, 0x030A}, 0x00C7: {0x0043, 0x0327}, 0x00C8: {0x0045, 0x0300},
+	0x00C9: {0x0045, 0x0301}, 0x00CA: {0x0045, 0x0302}, 0x00CB: {0x0045, 0x0308}, 0x00CC: {0x0049, 0x0300},
+	0x00CD: {0x0049, 0x0301}, 0x00CE: {0x0049, 0x0302}, 0x00CF: {0x0049, 0x0308}, 0x00D1: {0x004E, 0x0303},
+	0x00D2: {0x004F, 0x0300}, 0x00D3: {0x004F, 0x0301}, 0x00D4: {0x004F, 0x0302}, 0x00D5: {0x004F, 0x0303},
+	0x00D6: {0x004F, 0x0308}, 0x00D9: {0x0055, 0x0300}, 0x00DA: {0x0055, 0x0301}, 0x00DB: {0x0055, 0x0302},
+	0x00DC: {0x0055, 0x0308}, 0x00DD: {0x0059, 0x0301}, 0x00E0: {0x0061, 0x0300}, 0x00E1: {0x0061, 0x0301},
+	0x00E2: {0x0061, 0x0302}, 0x00E3: {0x0061, 0x0303}, 0x00E4: {0x0061, 0x0308}, 0x00E5: {0x0061, 0x030A},
+	0x00E7: {0x0063, 0x0327}, 0x00E8: {0x0065, 0x0300}, 0x00E9: {0x0065, 0x0301}, 0x00EA: {0x0065, 0x0302},
+	0x00EB: {0x0065, 0x0308}, 0x00EC: {0x0069, 0x0300}, 0x00ED: {0x0069, 0x0301}, 0x00EE: {0x0069, 0x0302},
+	0x00EF: {0x0069, 0x0308}, 0x00F1: {0x006E, 0x0303}, 0x00F2: {0x006F, 0x0300}, 0x00F3: {0x006F, 0x0301},
+	0x00F4: {0x006F, 0x0302}, 0x00F5: {0x006F, 0x0303}, 0x00F6: {0x006F, 0x0308}, 0x00F9: {0x0075, 0x0300},
+	0x00FA: {0x0075, 0x0301}, 0x00FB: {0x0075, 0x0302}, 0x00FC: {0x0075, 0x0308}, 0x00FD: {0x0079, 0x0301},
+	0x00FF: {0x0079, 0x0308}, 0x0100: {0x0041, 0x0304}, 0x0101: {0x0061, 0x0304}, 0x0102: {0x0041, 0x0306},
+	0x0103: {0x0061, 0x0306}, 0x0104: {0x0041, 0x0328}, 0x0105: {0x0061, 0x0328}, 0x0106: {0x0043, 0x0301},
+	0x0107: {0x0063, 0x0301}, 0x0108: {0x0043, 0x0302}, 0x0109: {0x0063, 0x0302}, 0x010A: {0x0043, 0x0307},
+	0x010B: {0x0063, 0x0307}, 0x010C: {0x0043, 0x030C}, 0x010D: {0x0063, 0x030C}, 0x010E: {0x0044, 0x030C},
+	0x010F: {0x0064, 0x030C}, 0x0112: {0x0045, 0x0304}, 0x0113: {0x0065, 0x0304}, 0x0114: {0x0045, 0x0306},
+	0x0115: {0x0065, 0x0306}, 0x0116: {0x0045, 0x0307}, 0x0117: {0x0065, 0x0307}, 0x0118: {0x0045, 0x0328},
+	0x0119: {0x0065, 0x0328}, 0x011A: {0x0045, 0x030C}, 0x011B: {0x0065, 0x030C}, 0x011C: {0x0047, 0x0302},
+	0x011D: {0x0067, 0x0302}, 0x011E: {0x0047, 0x0306}, 0x011F: {0x0067, 0x0306}, 0x0120: {0x0047, 0x0307},
+	0x0121: {0x0067, 0x0307}, 0x0122: {0x0047, 0x0327}, 0x0123: {0x0067, 0x0327}, 0x0124: {0x0048, 0x0302},
+	0x0125: {0x0068, 0x0302}, 0x0128: {0x0049, 0x0303}, 0x0129: {0x0069, 0x0303}, 0x012A: {0x0049, 0x0304},
+	0x012B: {0x0069, 0x0304}, 0x012C: {0x0049, 0x0306}, 0x012D: {0x0069, 0x0306}, 0x012E: {0x0049, 0x0328},
+	0x012F: {0x0069, 0x0328}, 0x0130: {0x0049, 0x0307}, 0x0134: {0x004A, 0x0302}, 0x0135: {0x006A, 0x0302},
+	0x0136: {0x004B, 0x0327}, 0x0137: {0x006B, 0x0327}, 0x0139: {0x004C, 0x0301}, 0x013A: {0x006C, 0x0301},
+	0x013B: {0x004C, 0x0327}, 0x013C: {0x006C, 0x0327}, 0x013D: {0x004C, 0x030C}, 0x013E: {0x006C, 0x030C},
+	0x0143: {0x004E, 0x0301}, 0x0144: {0x006E, 0x0301}, 0x0145: {0x004E, 0x0327}, 0x0146: {0x006E, 0x0327},
+	0x0147: {0x004E, 0x030C}, 0x0148: {0x006E, 0x030C}, 0x014C: {0x004F, 0x0304}, 0x014D: {0x006F, 0x0304},
+	0x014E: {0x004F, 0x0306}, 0x014F: {0x006F, 0x0306}, 0x0150: {0x004F, 0x030B}, 0x0151: {0x006F, 0x030B},
+	0x0154: {0x0052, 0x0301}, 0x0155: {0x0072, 0x0301}, 0x0156: {0x0052, 0x0327}, 0x0157: {0x0072, 0x0327},
+	0x0158: {0x0052, 0x030C}, 0x0159: {0x0072, 0x030C}, 0x015A: {0x0053, 0x0301}, 0x015B: {0x0073, 0x0301},
+	0x015C: {0x0053, 0x0302}, 0x015D: {0x0073, 0x0302}, 0x015E: {0x0053, 0x0327}, 0x015F: {0x0073, 0x0327},
+	0x0160: {0x0053, 0x030C}, 0x0161: {0x0073, 0x030C}, 0x0162: {0x0054, 0x0327}, 0x0163: {0x0074, 0x0327},
+	0x0164: {0x0054, 0x030C}, 0x0165: {0x0074, 0x030C}, 0x0168: {0x0055, 0x0303}, 0x0169: {0x0075, 0x0303},
+	0x016A: {0x0055, 0x0304}, 0x016B: {0x0075, 0x0304}, 0x016C: {0x0055, 0x0306}, 0x016D: {0x0075, 0x0306},
+	0x016E: {0x0055, 0x030A}, 0x016F: {0x0075, 0x030A}, 0x0170: {0x0055, 0x030B}, 0x0171: {0x0075, 0x030B},
+	0x0172: {0x0055, 0x0328}, 0x0173: {0x0075, 0x0328}, 0x0174: {0x0057, 0x0302}, 0x0175: {0x0077, 0x0302},
+	0x0176: {0x0059, 0x0302}, 0x0177: {0x0079, 0x0302}, 0x0178: {0x0059, 0x0308}, 0x0179: {0x005A, 0x0301},
+	0x017A: {0x007A, 0x0301}, 0x017B: {0x005A, 0x0307}, 0x017C: {0x007A, 0x0307}, 0x017D: {0x005A, 0x030C},
+	0x017E: {0x007A, 0x030C}, 0x1E00: {0x0041, 0x0325}, 0x1E01: {0x0061, 0x0325}, 0x1E02: {0x0042, 0x0307},
+	0x1E03: {0x0062, 0x0307}, 0x1E04: {0x0042, 0x0323}, 0x1E05: {0x0062, 0x0323}, 0x1E06: {0x0042, 0x0331},
+	0x1E07: {0x0062, 0x0331}, 0x1E08: {0x00C7, 0x0301}, 0x1E09: {0x00E7, 0x0301}, 0x1E0A: {0x0044, 0x0307},
+	0x1E0B: {0x0064, 0x0307}, 0x1E0C: {0x0044, 0x0323}, 0x1E0D: {0x0064, 0x0323}, 0x1E0E: {0x0044, 0x0331},
+	0x1E0F: {0x0064, 0x0331}, 0x1E10: {0x0044, 0x0327}, 0x1E11: {0x0064, 0x0327}, 0x1E12: {0x0044, 0x032D},
+	0x1E13: {0x0064, 0x032D}, 0x1E14: {0x0112, 0x0300}, 0x1E15: {0x0113, 0x0300}, 0x1E16: {0x0112, 0x0301},
+	0x1E17: {0x0113, 0x0301}, 0x1E18: {0x0045, 0x032D}, 0x1E19: {0x0065, 0x032D}, 0x1E1A: {0x0045, 0x0330},
+	0x1E1B: {0x0065, 0x0330}, 0x1E1C: {0x0228, 0x0306}, 0x1E1D: {0x0229, 0x0306}, 0x1E1E: {0x0046, 0x0307},
+	0x1E1F: {0x0066, 0x0307}, 0x1E20: {0x0047, 0x0304}, 0x1E21: {0x0067, 0x0304}, 0x1E22: {0x0048, 0x0307},
+	0x1E23: {0x0068, 0x0307}, 0x1E24: {0x0048, 0x0323}, 0x1E25: {0x0068, 0x0323}, 0x1E26: {0x0048, 0x0308},
+	0x1E27: {0x0068, 0x0308}, 0x1E28: {0x0048, 0x0327}, 0x1E29: {0x0068, 0x0327}, 0x1E2A: {0x0048, 0x032E},
+	0x1E2B: {0x0068, 0x032E}, 0x1E2C: {0x0049, 0x0330}, 0x1E2D: {0x0069, 0x0330}, 0x1E2E: {0x00CF, 0x0301},
+	0x1E2F: {0x00EF, 0x0301}, 0x1E30: {0x004B, 0x0301}, 0x1E31: {0x006B, 0x0301}, 0x1E32: {0x004B, 0x0323},
+	0x1E33: {0x006B, 0x0323}, 0x1E34: {0x004B, 0x0331}, 0x1E35: {0x006B, 0x0331}, 0x1E36: {0x004C, 0x0323},
+	0x1E37: {0x006C, 0x0323}, 0x1E38: {0x1E36, 0x0304}, 0x1E39: {0x1E37, 0x0304}, 0x1E3A: {0x004C, 0x0331},
+	0x1E3B: {0x006C, 0x0331}, 0x1E3C: {0x004C, 0x032D}, 0x1E3D: {0x006C, 0x032D}, 0x1E3E: {0x004D, 0x0301},
+	0x1E3F: {0x006D, 0x0301}, 0x1E40: {0x004D, 0x0307}, 0x1E41: {0x006D, 0x0307}, 0x1E42: {0x004D, 0x0323},
+	0x1E43: {0x006D, 0x0323}, 0x1E44: {0x004E, 0x0307}, 0x1E45: {0x006E, 0x0307}, 0x1E46: {0x004E, 0x0323},
+	0x1E47: {0x006E, 0x0323}, 0x1E48: {0x004E, 0x0331}, 0x1E49: {0x006E, 0x0331}, 0x1E4A: {0x004E, 0x032D},
+	0x1E4B: {0x006E, 0x032D}, 0x1E4C: {0x00D5, 0x0301}, 0x1E4D: {0x00F5, 0x0301}, 0x1E4E: {0x00D5, 0x0308},
+	0x1E4F: {0x00F5, 0x0308}, 0x1E50: {0x014C, 0x0300}, 0x1E51: {0x014D, 0x0300}, 0x1E52: {0x014C, 0x0301},
+	0x1E53: {0x014D, 0x0301}, 0x1E54: {0x0050, 0x0301}, 0x1E55: {0x0070, 0x0301}, 0x1E56: {0x0050, 0x0307},
+	0x1E57: {0x0070, 0x0307}, 0x1E58: {0x0052, 0x0307}, 0x1E59: {0x0072, 0x0307}, 0x1E5A: {0x0052, 0x0323},
+	0x1E5B: {0x0072, 0x0323}, 0x1E5C: {0x1E5A, 0x0304}, 0x1E5D: {0x1E5B, 0x0304}, 0x1E5E: {0x0052, 0x0331},
+	0x1E5F: {0x0072, 0x0331}, 0x1E60: {0x0053, 0x0307}, 0x1E61: {0x0073, 0x0307}, 0x1E62: {0x0053, 0x0323},
+	0x1E63: {0x0073, 0x0323}, 0x1E64: {0x015A, 0x0307}, 0x1E65: {0x015B, 0x0307}, 0x1E66: {0x0160, 0x0307},
+	0x1E67: {0x0161, 0x0307}, 0x1E68: {0x1E62, 0x0307}, 0x1E69: {0x1E63, 0x0307}, 0x1E6A: {0x0054, 0x0307},
+	0x1E6B: {0x0074, 0x0307}, 0x1E6C: {0x0054, 0x0323}, 0x1E6D: {0x0074, 0x0323}, 0x1E6E: {0x0054, 0x0331},
+	0x1E6F: {0x0074, 0x0331}, 0x1E70: {0x0054, 0x032D}, 0x1E71: {0x0074, 0x032D}, 0x1E72: {0x0055, 0x0324},
+	0x1E73: {0x0075, 0x0324}, 0x1E74: {0x0055, 0x0330}, 0x1E75: {0x0075, 0x0330}, 0x1E76: {0x0055, 0x032D},
+	0x1E77: {0x0075, 0x032D}, 0x1E78: {0x0168, 0x0301}, 0x1E79: {0x0169, 0x0301}, 0x1E7A: {0x016A, 0x0308},
+	0x1E7B: {0x016B, 0x0308}, 0x1E7C: {0x0056, 0x0303}, 0x1E7D: {0x0076, 0x0303}, 0x1E7E: {0x0056, 0x0323},
+	0x1E7F: {0x0076, 0x0323}, 0x1E80: {0x0057, 0x0300}, 0x1E81: {0x0077, 0x0300}, 0x1E82: {0x0057, 0x0301},
+	0x1E83: {0x0077, 0x0301}, 0x1E84: {0x0057, 0x0308}, 0x1E85: {0x0077, 0x0308}, 0x1E86: {0x0057, 0x0307},
+	0x1E87: {0x0077, 0x0307}, 0x1E88: {0x0057, 0x0323}, 0x1E89: {0x0077, 0x0323}, 0x1E8A: {0x0058, 0x0307},
+	0x1E8B: {0x0078, 0x0307}, 0x1E8C: {0x0058, 0x0308}, 0x1E8D: {0x0078, 0x0308}, 0x1E8E: {0x0059, 0x0307},
+	0x1E8F: {0x0079, 0x0307}, 0x1E90: {0x005A, 0x0302}, 0x1E91: {0x007A, 0x0302}, 0x1E92: {0x005A, 0x0323},
+	0x1E93: {0x007A, 0x0323}, 0x1E94: {0x005A, 0x0331}, 0x1E95: {0x007A, 0x0331}, 0x1E96: {0x0068, 0x0331},
+	0x1E97: {0x0074, 0x0308}, 0x1E98: {0x0077, 0x030A}, 0x1E99: {0x0079, 0x030A}, 0x1E9B: {0x017F, 0x0307},
+	0x1EA0: {0x0041, 0x0323}, 0x1EA1: {0x0061, 0x0323}, 0x1EA2: {0x0041, 0x0309}, 0x1EA3: {0x0061, 0x0309},
+	0x1EA4: {0x00C2, 0x0301}, 0x1EA5: {0x00E2, 0x0301}, 0x1EA6: {0x00C2, 0x0300}, 0x1EA7: {0x00E2, 0x0300},
+	0x1EA8: {0x00C2, 0x0309}, 0x1EA9: {0x00E2, 0x0309}, 0x1EAA: {0x00C2, 0x0303}, 0x1EAB: {0x00E2, 0x0303},
+	0x1EAC: {0x1EA0, 0x0302}, 0x1EAD: {0x1EA1, 0x0302}, 0x1EAE: {0x0102, 0x0301}, 0x1EAF: {0x0103, 0x0301},
+	0x1EB0: {0x0102, 0x0300}, 0x1EB1: {0x0103, 0x0300}, 0x1EB2: {0x0102, 0x0309}, 0x1EB3: {0x0103, 0x0309},
+	0x1EB4: {0x0102, 0x0303}, 0x1EB5: {0x0103, 0x0303}, 0x1EB6: {0x1EA0, 0x0306}, 0x1EB7: {0x1EA1, 0x0306},
+	0x1EB8: {0x0045, 0x0323}, 0x1EB9: {0x0065, 0x0323}, 0x1EBA: {0x0045, 0x0309}, 0x1EBB: {0x0065, 0x0309},
+	0x1EBC: {0x0045, 0x0303}, 0x1EBD: {0x0065, 0x0303}, 0x1EBE: {0x00CA, 0x0301}, 0x1EBF: {0x00EA, 0x0301},
+	0x1EC0: {0x00CA, 0x0300}, 0x1EC1: {0x00EA, 0x0300}, 0x1EC2: {0x00CA, 0x0309}, 0x1EC3: {0x00EA, 0x0309},
+	0x1EC4: {0x00CA, 0x0303}, 0x1EC5: {0x00EA, 0x0303}, 0x1EC6: {0x1EB8, 0x0302}, 0x1EC7: {0x1EB9, 0x0302},
+	0x1EC8: {0x0049, 0x0309}, 0x1EC9: {0x0069, 0x0309}, 0x1ECA: {0x0049, 0x0323}, 0x1ECB: {0x0069, 0x0323},
+	0x1ECC: {0x004F, 0x0323}, 0x1ECD: {0x006F, 0x0323}, 0x1ECE: {0x004F, 0x0309}, 0x1ECF: {0x006F, 0x0309},
+	0x1ED0: {0x00D4, 0x0301}, 0x1ED1: {0x00F4, 0x0301}, 0x1ED2: {0x00D4, 0x0300}, 0x1ED3: {0x00F4, 0x0300},
+	0x1ED4: {0x00D4, 0x0309}, 0x1ED5: {0x00F4, 0x0309}, 0x1ED6: {0x00D4, 0x0303}, 0x1ED7: {0x00F4, 0x0303},
+	0x1ED8: {0x1ECC, 0x0302}, 0x1ED9: {0x1ECD, 0x0302}, 0x1EDA: {0x01A0, 0x0301}, 0x1EDB: {0x01A1, 0x0301},
+	0x1EDC: {0x01A0, 0x0300}, 0x1EDD: {0x01A1, 0x0300}, 0x1EDE: {0x01A0, 0x0309}, 0x1EDF: {0x01A1, 0x0309},
+	0x1EE0: {0x01A0, 0x0303}, 0x1EE1: {0x01A1, 0x0303}, 0x1EE2: {0x01A0, 0x0323}, 0x1EE3: {0x01A1, 0x0323},
+	0x1EE4: {0x0055, 0x0323}, 0x1EE5: {0x0075, 0x0323}, 0x1EE6: {0x0055, 0x0309}, 0x1EE7: {0x0075, 0x0309},
+	0x1EE8: {0x01AF, 0x0301}, 0x1EE9: {0x01B0, 0x0301}, 0x1EEA: {0x01AF, 0x0300}, 0x1EEB: {0x01B0, 0x0300},
+	0x1EEC: {0x01AF, 0x0309}, 0x1EED: {0x01B0, 0x0309}, 0x1EEE: {0x01AF, 0x0303}, 0x1EEF: {0x01B0, 0x0303},
+	0x1EF0: {0x01AF, 0x0323}, 0x1EF1: {0x01B0, 0x0323}, 0x1EF2: {0x0059, 0x0300}, 0x1EF3: {0x0079, 0x0300},
+	0x1EF4: {0x0059, 0x0323}, 0x1EF5: {0x0079, 0x0323}, 0x1EF6: {0x0059, 0x0309}, 0x1EF7: {0x0079, 0x0309},
+	0x1EF8: {0x0059, 0x0303}, 0x1EF9: {0x0079, 0x0303}, 0x0400: {0x0415, 0x0300}, 0x0401: {0x0415, 0x0308},
+	0x0403: {0x0413, 0x0301}, 0x0407: {0x0406, 0x0308}, 0x040C: {0x041A, 0x0301}, 0x040D: {0x0418, 0x0300},
+	0x040E: {0x0423, 0x0306}, 0x0419: {0x0418, 0x0306}, 0x0439: {0x0438, 0x0306}, 0x0450: {0x0435, 0x0300},
+	0x0451: {0x0435, 0x0308}, 0x0453: {0x0433, 0x0301}, 0x0457: {0x0456, 0x0308}, 0x045C: {0x043A, 0x0301},
+	0x045D: {0x0438, 0x0300}, 0x045E: {0x0443, 0x0306}, 0x0476: {0x0474, 0x030F}, 0x0477: {0x0475, 0x030F},
+	0x04C1: {0x0416, 0x0306}, 0x04C2: {0x0436, 0x0306}, 0x04D0: {0x0410, 0x0306}, 0x04D1: {0x0430, 0x0306},
+	0x04D2: {0x0410, 0x0308}, 0x04D3: {0x0430, 0x0308}, 0x04D6: {0x0415, 0x0306}, 0x04D7: {0x0435, 0x0306},
+	0x04DA: {0x04D8, 0x0308}, 0x04DB: {0x04D9, 0x0308}, 0x04DC: {0x0416, 0x0308}, 0x04DD: {0x0436, 0x0308},
+	0x04DE: {0x0417, 0x0308}, 0x04DF: {0x0437, 0x0308}, 0x04E2: {0x0418, 0x0304}, 0x04E3: {0x0438, 0x0304},
+	0x04E4: {0x0418, 0x0308}, 0x04E5: {0x0438, 0x0308}, 0x04E6: {0x041E, 0x0308}, 0x04E7: {0x043E, 0x0308},
+	0x04EA: {0x04E8, 0x0308}, 0x04EB: {0x04E9, 0x0308}, 0x04EC: {0x042D, 0x0308}, 0x04ED: {0x044D, 0x0308},
+	0x04EE: {0x0423, 0x0304}, 0x04EF: {0x0443, 0x0304}, 0x04F0: {0x0423, 0x0308}, 0x04F1: {0x0443, 0x0308},
+	0x04F2: {0x0423, 0x030B}, 0x04F3: {0x0443, 0x030B}, 0x04F4: {0x0427, 0x0308}, 0x04F5: {0x0447, 0x0308},
+	0x04F8: {0x042B, 0x0308}, 0x04F9: {0x044B, 0x0308},
+}
+
+// combiningClasses - классы канонического комбинирования (Canonical_Combining_Class) для знаков из decompositions.
+var combiningClasses = map[rune]uint8{
+	0x0300: 230, 0x0301: 230, 0x0302: 230, 0x0303: 230, 0x0304: 230, 0x0306: 230,
+	0x0307: 230, 0x0308: 230, 0x0309: 230, 0x030A: 230, 0x030B: 230, 0x030C: 230,
+	0x030F: 230, 0x0323: 220, 0x0324: 220, 0x0325: 220, 0x0327: 202, 0x0328: 202,
+	0x032D: 220, 0x032E: 220, 0x0330: 220, 0x0331: 220,
+}
+
+// compatibilityDecompositions - совместимые разложения для лигатур, полноширинных форм, надстрочных и подстрочных
+// цифр.
+var compatibilityDecompositions = map[rune]string{
+	0x00B2: "2", 0x00B3: "3", 0x00B9: "1", 0x2070: "0", 0x2071: "i", 0x2074: "4",
+	0x2075: "5", 0x2076: "6", 0x2077: "7", 0x2078: "8", 0x2079: "9", 0x2080: "0",
+	0x2081: "1", 0x2082: "2", 0x2083: "3", 0x2084: "4", 0x2085: "5", 0x2086: "6",
+	0x2087: "7", 0x2088: "8", 0x2089: "9", 0xFB00: "ff", 0xFB01: "fi", 0xFB02: "fl",
+	0xFB03: "ffi", 0xFB04: "ffl", 0xFB05: "st", 0xFB06: "st", 0xFF01: "!", 0xFF02: "\"",
+	0xFF03: "#", 0xFF04: "$", 0xFF05: "%", 0xFF06: "&", 0xFF07: "'", 0xFF08: "(",
+	0xFF09: ")", 0xFF0A: "*", 0xFF0B: "+", 0xFF0C: ",", 0xFF0D: "-", 0xFF0E: ".",
+	0xFF0F: "/", 0xFF10: "0", 0xFF11: "1", 0xFF12: "2", 0xFF13: "3", 0xFF14: "4",
+	0xFF15: "5", 0xFF16: "6", 0xFF17: "7", 0xFF18: "8", 0xFF19: "9", 0xFF1A: ":",
+	0xFF1B: ";", 0xFF1C: "<", 0xFF1D: "=", 0xFF1E: ">", 0xFF1F: "?", 0xFF20: "@",
+	0xFF21: "A", 0xFF22: "B", 0xFF23: "C", 0xFF24: "D", 0xFF25: "E", 0xFF26: "F",
+	0xFF27: "G", 0xFF28: "H", 0xFF29: "I", 0xFF2A: "J", 0xFF2B: "K", 0xFF2C: "L",
+	0xFF2D: "M", 0xFF2E: "N", 0xFF2F: "O", 0xFF30: "P", 0xFF31: "Q", 0xFF32: "R",
+	0xFF33: "S", 0xFF34: "T", 0xFF35: "U", 0xFF36: "V", 0xFF37: "W", 0xFF38: "X",
+	0xFF39: "Y", 0xFF3A: "Z", 0xFF3B: "[", 0xFF3C: "\\", 0xFF3D: "]", 0xFF3E: "^",
+	0xFF3F: "_", 0xFF40: "`", 0xFF41: "a", 0xFF42: "b", 0xFF43: "c", 0xFF44: "d",
+	0xFF45: "e", 0xFF46: "f", 0xFF47: "g", 0xFF48: "h", 0xFF49: "i", 0xFF4A: "j",
+	0xFF4B: "k", 0xFF4C: "l", 0xFF4D: "m", 0xFF4E: "n", 0xFF4F: "o", 0xFF50: "p",
+	0xFF51: "q", 0xFF52: "r", 0xFF53: "s", 0xFF54: "t", 0xFF55: "u", 0xFF56: "v",
+	0xFF57: "w", 0xFF58: "x", 0xFF59: "y", 0xFF5A: "z", 0xFF5B: "{", 0xFF5C: "|",
+	0xFF5D: "}", 0xFF5E: "~",
+}
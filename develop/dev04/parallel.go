@@ -0,0 +1,115 @@
+package main
+
+import (
+	"hash/fnv"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// GroupAnagramsParallel делает то же самое, что и GroupAnagrams, но распределяет работу между workers горутинами.
+// Если workers не больше нуля, используется runtime.GOMAXPROCS(0) горутин.
+func GroupAnagramsParallel(words []string, workers int) *map[string][]string {
+	return Russian.GroupAnagramsParallel(words, workers)
+}
+
+// GroupAnagramsParallel группирует слова из words так же, как Alphabet.GroupAnagrams, используя workers горутин.
+//
+// Работа выполняется в два этапа:
+//  1. Слова делятся на последовательные части, для каждого слова вычисляется сигнатура и номер шарда, в который оно
+//     попадёт. Шард определяется по хэшу сигнатуры, поэтому все анаграммы оказываются в одном шарде.
+//  2. Каждый шард группируется отдельно. Индексы слов внутри шарда идут по возрастанию, поэтому первое слово группы
+//     в шарде - это и первое слово группы во всём словаре, а значит ключи групп совпадают с последовательной версией.
+func (a *Alphabet) GroupAnagramsParallel(words []string, workers int) *map[string][]string {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	signatures := make([]Signature, len(words))
+
+	// buckets[chunk][shard] - индексы слов части chunk, попавших в шард shard
+	chunkSize := (len(words) + workers - 1) / workers
+	buckets := make([][][]int, workers)
+
+	wg := &sync.WaitGroup{}
+	for chunk := 0; chunk < workers; chunk++ {
+		wg.Add(1)
+
+		go func(chunk int) {
+			defer wg.Done()
+
+			buckets[chunk] = make([][]int, workers)
+			start, end := chunk*chunkSize, (chunk+1)*chunkSize
+			if end > len(words) {
+				end = len(words)
+			}
+
+			for i := start; i < end; i++ {
+				signatures[i] = a.Hash(words[i])
+				shard := shardOf(signatures[i], workers)
+				buckets[chunk][shard] = append(buckets[chunk][shard], i)
+			}
+		}(chunk)
+	}
+
+	wg.Wait()
+
+	// Группируем каждый шард отдельно
+	shards := make([]map[string][]string, workers)
+	for shard := 0; shard < workers; shard++ {
+		wg.Add(1)
+
+		go func(shard int) {
+			defer wg.Done()
+
+			groups := make(map[Signature]*Group)
+			order := make([]*Group, 0)
+
+			// Проходим части по порядку, чтобы индексы слов возрастали
+			for chunk := 0; chunk < workers; chunk++ {
+				for _, i := range buckets[chunk][shard] {
+					group, ok := groups[signatures[i]]
+					if !ok {
+						group = &Group{Key: words[i], Signature: signatures[i]}
+						groups[signatures[i]] = group
+						order = append(order, group)
+					}
+
+					group.Words = append(group.Words, words[i])
+				}
+			}
+
+			result := make(map[string][]string)
+			for _, group := range order {
+				// Убираем группы, где меньше двух слов
+				if group.Words.Len() < 2 {
+					continue
+				}
+
+				sort.Sort(group.Words)
+				result[group.Key] = group.Words
+			}
+
+			shards[shard] = result
+		}(shard)
+	}
+
+	wg.Wait()
+
+	// Объединяем результаты шардов. Ключи разных шардов не пересекаются, поскольку у разных групп разные первые слова.
+	result := make(map[string][]string)
+	for _, groups := range shards {
+		for key, group := range groups {
+			result[key] = group
+		}
+	}
+
+	return &result
+}
+
+// shardOf возвращает номер шарда для сигнатуры
+func shardOf(signature Signature, shards int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(signature))
+	return int(h.Sum32() % uint32(shards))
+}
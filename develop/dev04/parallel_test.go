@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupAnagramsParallel(t *testing.T) {
+	words := generateDictionary(50000)
+
+	// Слово, повторяющееся в разных частях словаря, и его анаграмма в самом конце
+	words = append([]string{"пятак"}, words...)
+	words = append(words, "пятак", "тяпка")
+
+	expected := *GroupAnagrams(words)
+
+	for _, workers := range []int{0, 1, 2, 3, 7, 16} {
+		actual := *GroupAnagramsParallel(words, workers)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("result with %d workers differs from sequential version", workers)
+		}
+	}
+
+	// Крайние случаи: пустой словарь и воркеров больше, чем слов
+	for _, input := range [][]string{{}, {"пятак", "пятка"}} {
+		actual := *GroupAnagramsParallel(input, 8)
+		if !reflect.DeepEqual(actual, *GroupAnagrams(input)) {
+			t.Errorf("unexpected result for %v: %v", input, actual)
+		}
+	}
+}
+
+func BenchmarkGroupAnagrams(b *testing.B) {
+	words := generateDictionary(500000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		GroupAnagrams(words)
+	}
+}
+
+func BenchmarkGroupAnagramsParallel(b *testing.B) {
+	words := generateDictionary(500000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		GroupAnagramsParallel(words, 0)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrBudgetExceeded возвращается поиском анаграмм, если перебор был прерван по времени или по количеству результатов.
+// Найденные к этому моменту результаты при этом тоже возвращаются.
+var ErrBudgetExceeded = errors.New("search budget exceeded")
+
+// SearchBudget ограничивает перебор при поиске анаграмм. Нулевое значение поля означает отсутствие ограничения.
+type SearchBudget struct {
+	// MaxWords - максимальное количество слов в составной анаграмме (глубина перебора)
+	MaxWords int
+
+	// MaxResults - максимальное количество результатов
+	MaxResults int
+
+	// Timeout - максимальное время поиска
+	Timeout time.Duration
+}
+
+// LetterTrie - префиксное дерево, построенное по сигнатурам слов. Путь от корня до узла состоит из отсортированных букв
+// сигнатуры, поэтому все анаграммы оказываются в одном узле, а поиск слов, которые можно составить из заданного набора
+// букв, сводится к обходу дерева с уменьшением количества оставшихся букв.
+type LetterTrie struct {
+	alphabet *Alphabet
+	root     *trieNode
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+
+	// Буквы дочерних узлов по возрастанию, чтобы обход дерева был детерминированным
+	letters []rune
+
+	// Слова, сигнатура которых совпадает с путём до узла, в порядке добавления
+	words     []string
+	signature Signature
+}
+
+// NewLetterTrie строит дерево по словам из words, определяя их сигнатуры по правилам алфавита alphabet.
+func NewLetterTrie(alphabet *Alphabet, words []string) *LetterTrie {
+	t := &LetterTrie{
+		alphabet: alphabet,
+		root:     &trieNode{},
+	}
+
+	for _, word := range words {
+		t.Add(word)
+	}
+
+	return t
+}
+
+// Add добавляет слово в дерево. Слова без букв алфавита и повторно добавленные слова игнорируются.
+func (t *LetterTrie) Add(word string) {
+	signature := t.alphabet.Hash(word)
+	if signature == "" {
+		return
+	}
+
+	node := t.root
+	for _, r := range signature {
+		child, ok := node.children[r]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[rune]*trieNode)
+			}
+
+			child = &trieNode{}
+			node.children[r] = child
+
+			// Вставляем букву так, чтобы список оставался отсортированным
+			i := sort.Search(len(node.letters), func(i int) bool {
+				return node.letters[i] >= r
+			})
+			node.letters = append(node.letters, 0)
+			copy(node.letters[i+1:], node.letters[i:])
+			node.letters[i] = r
+		}
+
+		node = child
+	}
+
+	for _, w := range node.words {
+		if w == word {
+			return
+		}
+	}
+
+	node.signature = signature
+	node.words = append(node.words, word)
+}
+
+// letterCounts - количество оставшихся букв каждого вида
+type letterCounts map[rune]int
+
+// counts возвращает количество каждой из букв фразы, используя ту же нормализацию, что и Hash.
+func (t *LetterTrie) counts(phrase string) (letterCounts, int) {
+	counts := make(letterCounts)
+	letters := t.alphabet.Letters(phrase)
+
+	for _, r := range letters {
+		counts[r]++
+	}
+
+	return counts, len(letters)
+}
+
+// searchState хранит общие для всего перебора ограничения
+type searchState struct {
+	budget   SearchBudget
+	deadline time.Time
+	steps    int
+	results  int
+	exceeded bool
+}
+
+func newSearchState(budget SearchBudget) *searchState {
+	s := &searchState{budget: budget}
+	if budget.Timeout > 0 {
+		s.deadline = time.Now().Add(budget.Timeout)
+	}
+
+	return s
+}
+
+// step учитывает очередной шаг перебора и возвращает false, если перебор нужно прекратить
+func (s *searchState) step() bool {
+	if s.exceeded {
+		return false
+	}
+
+	// Время проверяем не на каждом шаге, поскольку time.Now не бесплатен
+	s.steps++
+	if !s.deadline.IsZero() && s.steps%1024 == 0 && time.Now().After(s.deadline) {
+		s.exceeded = true
+	}
+
+	return !s.exceeded
+}
+
+// found вызывается перед добавлением найденного результата. Возвращает false и прекращает перебор, если результатов
+// уже MaxResults: бюджет считается превышенным, только если нашёлся результат сверх лимита.
+func (s *searchState) found() bool {
+	if s.budget.MaxResults > 0 && s.results >= s.budget.MaxResults {
+		s.exceeded = true
+		return false
+	}
+
+	s.results++
+	return true
+}
+
+func (s *searchState) err() error {
+	if s.exceeded {
+		return ErrBudgetExceeded
+	}
+
+	return nil
+}
+
+// walk обходит узлы дерева, которые можно составить из букв counts, и вызывает visit для каждого узла со словами.
+// Перед вызовом visit буквы пути уже вычтены из counts. Если visit возвращает false, обход прекращается.
+func (s *searchState) walk(node *trieNode, counts letterCounts, visit func(*trieNode) bool) bool {
+	if !s.step() {
+		return false
+	}
+
+	if len(node.words) > 0 && !visit(node) {
+		return false
+	}
+
+	for _, r := range node.letters {
+		if counts[r] == 0 {
+			continue
+		}
+
+		counts[r]--
+		ok := s.walk(node.children[r], counts, visit)
+		counts[r]++
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SubAnagrams возвращает все слова дерева, которые можно составить из части букв фразы phrase (каждую букву можно
+// использовать не больше раз, чем она встречается во фразе). Слова упорядочены по убыванию длины, затем по алфавиту.
+func (t *LetterTrie) SubAnagrams(phrase string, budget SearchBudget) ([]string, error) {
+	counts, _ := t.counts(phrase)
+	state := newSearchState(budget)
+	result := make([]string, 0)
+
+	state.walk(t.root, counts, func(node *trieNode) bool {
+		for _, word := range node.words {
+			if !state.found() {
+				return false
+			}
+
+			result = append(result, word)
+		}
+
+		return true
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := []rune(result[i]), []rune(result[j])
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+
+		return result[i] < result[j]
+	})
+
+	return result, state.err()
+}
+
+// MultiWordAnagrams возвращает все наборы слов дерева, вместе использующие каждую букву фразы phrase ровно один раз.
+// Наборы, отличающиеся только порядком слов, считаются одинаковыми: слова в наборе упорядочены по сигнатурам.
+func (t *LetterTrie) MultiWordAnagrams(phrase string, budget SearchBudget) ([][]string, error) {
+	counts, total := t.counts(phrase)
+	state := newSearchState(budget)
+	result := make([][]string, 0)
+
+	if total == 0 {
+		return result, nil
+	}
+
+	// Набор узлов, выбранных на текущей ветке перебора
+	chosen := make([]*trieNode, 0)
+
+	var search func(remaining int) bool
+	search = func(remaining int) bool {
+		if budget.MaxWords > 0 && len(chosen) >= budget.MaxWords {
+			return true
+		}
+
+		// Чтобы не получать перестановки одного и того же набора, сигнатура каждого следующего слова должна быть не
+		// меньше предыдущей
+		var min Signature
+		if len(chosen) > 0 {
+			min = chosen[len(chosen)-1].signature
+		}
+
+		// Узлы, найденные обходом, запоминаем, а перебираем уже после обхода: вложенный поиск меняет counts
+		candidates := make([]*trieNode, 0)
+		ok := state.walk(t.root, counts, func(node *trieNode) bool {
+			if node.signature >= min {
+				candidates = append(candidates, node)
+			}
+
+			return true
+		})
+		if !ok {
+			return false
+		}
+
+		for _, node := range candidates {
+			letters := []rune(node.signature)
+			for _, r := range letters {
+				counts[r]--
+			}
+
+			chosen = append(chosen, node)
+
+			if remaining == len(letters) {
+				ok = expand(chosen, func(words []string) bool {
+					if !state.found() {
+						return false
+					}
+
+					result = append(result, words)
+					return true
+				})
+			} else {
+				ok = search(remaining - len(letters))
+			}
+
+			chosen = chosen[:len(chosen)-1]
+			for _, r := range letters {
+				counts[r]++
+			}
+
+			if !ok {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	search(total)
+	return result, state.err()
+}
+
+// expand перебирает все наборы слов, в которых i-е слово взято из i-го узла nodes. Если один и тот же узел выбран
+// несколько раз подряд, слова из него берутся в неубывающем порядке, чтобы не получать перестановки.
+func expand(nodes []*trieNode, emit func([]string) bool) bool {
+	words := make([]string, len(nodes))
+
+	var fill func(i, from int) bool
+	fill = func(i, from int) bool {
+		if i == len(nodes) {
+			return emit(append([]string{}, words...))
+		}
+
+		if i == 0 || nodes[i] != nodes[i-1] {
+			from = 0
+		}
+
+		for j := from; j < len(nodes[i].words); j++ {
+			words[i] = nodes[i].words[j]
+			if !fill(i+1, j) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return fill(0, 0)
+}
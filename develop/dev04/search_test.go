@@ -0,0 +1,118 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLetterTrie_SubAnagrams(t *testing.T) {
+	trie := NewLetterTrie(Russian, []string{"пятак", "пятка", "тяпка", "пята", "кит", "як", "тяпкаа", "ёж", "Пятак"})
+
+	words, err := trie.SubAnagrams("Пятка!", SearchBudget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Пятак", "пятак", "пятка", "тяпка", "пята", "як"}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("unexpected result: %v (expected %v)", words, expected)
+	}
+
+	words, err = trie.SubAnagrams("пятка", SearchBudget{MaxResults: 2})
+	if err != ErrBudgetExceeded || len(words) != 2 {
+		t.Errorf("unexpected result with budget: %v, %v", words, err)
+	}
+
+	// Ровно MaxResults результатов - бюджет не превышен
+	words, err = trie.SubAnagrams("Пятка!", SearchBudget{MaxResults: len(expected)})
+	if err != nil || len(words) != len(expected) {
+		t.Errorf("unexpected result with exact budget: %v, %v", words, err)
+	}
+}
+
+func TestLetterTrie_MultiWordAnagrams(t *testing.T) {
+	trie := NewLetterTrie(English, []string{"ant", "tan", "nat", "act", "cat", "at", "ta", "a", "can", "tact"})
+
+	combinations, err := trie.MultiWordAnagrams("tan cat", SearchBudget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"act", "ant"}, {"act", "tan"}, {"act", "nat"}, {"cat", "ant"}, {"cat", "tan"}, {"cat", "nat"},
+	}
+
+	// Порядок результатов зависит от порядка обхода, поэтому сравниваем как множества
+	if !sameCombinations(combinations, expected) {
+		t.Errorf("unexpected result: %v (expected %v)", combinations, expected)
+	}
+
+	combinations, err = trie.MultiWordAnagrams("tan cat", SearchBudget{MaxWords: 1})
+	if err != nil || len(combinations) != 0 {
+		t.Errorf("unexpected result with MaxWords: %v, %v", combinations, err)
+	}
+
+	combinations, err = trie.MultiWordAnagrams("atat", SearchBudget{})
+	if err != nil || !sameCombinations(combinations, [][]string{{"at", "at"}, {"at", "ta"}, {"ta", "ta"}}) {
+		t.Errorf("unexpected result for repeated words: %v, %v", combinations, err)
+	}
+
+	combinations, err = trie.MultiWordAnagrams("tan cat", SearchBudget{MaxResults: len(expected)})
+	if err != nil || !sameCombinations(combinations, expected) {
+		t.Errorf("unexpected result with exact budget: %v, %v", combinations, err)
+	}
+
+	combinations, err = trie.MultiWordAnagrams("tan cat", SearchBudget{MaxResults: len(expected) - 1})
+	if err != ErrBudgetExceeded || len(combinations) != len(expected)-1 {
+		t.Errorf("unexpected result with budget: %v, %v", combinations, err)
+	}
+}
+
+func TestLetterTrie_Timeout(t *testing.T) {
+	// Словарь из всех однобуквенных и двухбуквенных слов даёт огромное количество разбиений длинной фразы
+	letters := []rune("abcdefghij")
+	words := make([]string, 0)
+	for _, a := range letters {
+		words = append(words, string(a))
+		for _, b := range letters {
+			words = append(words, string([]rune{a, b}))
+		}
+	}
+
+	trie := NewLetterTrie(English, words)
+	start := time.Now()
+	_, err := trie.MultiWordAnagrams("abcdefghijabcdefghijabcdefghij", SearchBudget{Timeout: 50 * time.Millisecond})
+
+	if err != ErrBudgetExceeded {
+		t.Errorf("expected budget error, got %v", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Errorf("search ignored timeout: %s", time.Since(start))
+	}
+}
+
+// sameCombinations сравнивает два списка наборов слов без учёта порядка наборов
+func sameCombinations(actual, expected [][]string) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+
+	counts := make(map[string]int)
+	for _, c := range expected {
+		counts[strings.Join(c, " ")]++
+	}
+
+	for _, c := range actual {
+		key := strings.Join(c, " ")
+		if counts[key] == 0 {
+			return false
+		}
+
+		counts[key]--
+	}
+
+	return true
+}
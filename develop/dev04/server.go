@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Response - обёртка для ответов сервера
+type Response struct {
+	Result any `json:"result"`
+}
+
+// ErrorResponse - обёртка для обработанных ошибок
+type ErrorResponse struct {
+	code    int
+	Message string `json:"error"`
+}
+
+func NewErrorResponse(code int, message string) *ErrorResponse {
+	return &ErrorResponse{code: code, Message: message}
+}
+
+func (e *ErrorResponse) Error() string {
+	return e.Message
+}
+
+// write записывает в http.ResponseWriter данную ошибку
+func (e *ErrorResponse) write(w http.ResponseWriter) {
+	writeResponse(w, e, e.code)
+}
+
+// DictionaryResponse - ответ на загрузку словаря
+type DictionaryResponse struct {
+	Words  int `json:"words"`
+	Groups int `json:"groups"`
+}
+
+// GroupsResponse - страница списка групп
+type GroupsResponse struct {
+	Total  int      `json:"total"`
+	Offset int      `json:"offset"`
+	Groups []*Group `json:"groups"`
+}
+
+// PageRequest - запрос, содержащий параметры постраничного вывода
+type PageRequest struct {
+	Offset  int
+	Limit   int
+	MinSize int
+}
+
+// Parse загружает значения из данного url.Values в структуру. Отсутствующие параметры получают значения по
+// умолчанию, limit не может превышать maxLimit.
+func (p *PageRequest) Parse(values url.Values, maxLimit int) error {
+	p.Offset, p.Limit, p.MinSize = 0, maxLimit, 2
+
+	params := map[string]*int{"offset": &p.Offset, "limit": &p.Limit, "min_size": &p.MinSize}
+	for name, value := range params {
+		raw := values.Get(name)
+		if raw == "" {
+			continue
+		}
+
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return NewErrorResponse(http.StatusBadRequest, "invalid "+name)
+		}
+
+		*value = v
+	}
+
+	if p.Limit > maxLimit {
+		p.Limit = maxLimit
+	}
+
+	return nil
+}
+
+// handler - функция, обрабатывающая запрос и возвращающая некоторый ответ или ошибку
+type handler func(r *http.Request) (any, error)
+
+// AnagramServer содержит методы, реализующие API поиска анаграмм
+type AnagramServer struct {
+	index    *AnagramIndex
+	alphabet *Alphabet
+	lock     *sync.RWMutex
+
+	// Загрузки словарей выполняются по одной, чтобы дополнение словаря не потерялось при одновременной замене
+	uploadLock *sync.Mutex
+
+	// MaxBodySize - максимальный размер тела запроса в байтах
+	MaxBodySize int64
+
+	// MaxPageSize - максимальное количество групп на одной странице
+	MaxPageSize int
+}
+
+func NewAnagramServer(index *AnagramIndex, alphabet *Alphabet) *AnagramServer {
+	return &AnagramServer{
+		index:       index,
+		alphabet:    alphabet,
+		lock:        &sync.RWMutex{},
+		uploadLock:  &sync.Mutex{},
+		MaxBodySize: 10 << 20,
+		MaxPageSize: 100,
+	}
+}
+
+// Index возвращает текущий индекс. Индекс целиком заменяется при загрузке нового словаря.
+func (s *AnagramServer) Index() *AnagramIndex {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.index
+}
+
+// Mux создаёт http.ServeMux со всеми обработчиками методов API
+func (s *AnagramServer) Mux() *http.ServeMux {
+	mux := new(http.ServeMux)
+
+	mux.Handle("/dictionary", s.makeHandler(http.MethodPost, s.UploadDictionary))
+	mux.Handle("/anagrams", s.makeHandler(http.MethodGet, s.Anagrams))
+	mux.Handle("/groups", s.makeHandler(http.MethodGet, s.Groups))
+
+	return mux
+}
+
+// UploadDictionary - метод API для загрузки словаря: одно слово в строке, строки с "#" в начале пропускаются. По
+// умолчанию словарь заменяет текущий, с параметром mode=append слова добавляются к текущему словарю.
+func (s *AnagramServer) UploadDictionary(r *http.Request) (any, error) {
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "replace" && mode != "append" {
+		return nil, NewErrorResponse(http.StatusBadRequest, "mode must be replace or append")
+	}
+
+	words, err := ReadDictionary(r.Body, DictionaryOptions{CommentPrefix: "#", FrequencyField: -1})
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, NewErrorResponse(http.StatusRequestEntityTooLarge, "dictionary is too large")
+		}
+
+		return nil, NewErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	s.uploadLock.Lock()
+	defer s.uploadLock.Unlock()
+
+	// Новый словарь собираем отдельно и подменяем индекс целиком, чтобы запросы во время загрузки видели либо старый,
+	// либо новый словарь. При дополнении слова добавляются в копию текущего индекса, а не в тот, что читают запросы.
+	index := NewAnagramIndex(s.alphabet)
+	if mode == "append" {
+		index = s.Index().Clone()
+	}
+
+	for _, word := range words {
+		index.Add(word)
+	}
+
+	s.lock.Lock()
+	s.index = index
+	s.lock.Unlock()
+
+	return &DictionaryResponse{
+		Words:  index.Len(),
+		Groups: len(index.Groups(2)),
+	}, nil
+}
+
+// Anagrams - метод API для получения группы анаграмм слова из параметра word
+func (s *AnagramServer) Anagrams(r *http.Request) (any, error) {
+	word := strings.TrimSpace(r.URL.Query().Get("word"))
+	if word == "" {
+		return nil, NewErrorResponse(http.StatusBadRequest, "word cannot be empty")
+	}
+
+	group, ok := s.Index().Lookup(word)
+	if !ok {
+		return nil, NewErrorResponse(http.StatusNotFound, "no anagrams found")
+	}
+
+	return group, nil
+}
+
+// Groups - метод API для постраничного получения групп анаграмм в порядке добавления их ключей в словарь
+func (s *AnagramServer) Groups(r *http.Request) (any, error) {
+	page := new(PageRequest)
+	err := page.Parse(r.URL.Query(), s.MaxPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := s.Index().Groups(page.MinSize)
+	response := &GroupsResponse{
+		Total:  len(groups),
+		Offset: page.Offset,
+		Groups: make([]*Group, 0),
+	}
+
+	if page.Offset < len(groups) {
+		end := page.Offset + page.Limit
+		if end > len(groups) {
+			end = len(groups)
+		}
+
+		response.Groups = groups[page.Offset:end]
+	}
+
+	return response, nil
+}
+
+// makeHandler оборачивает функцию-обработчик, преобразуя её возвращаемые значения в ответ HTTP
+func (s *AnagramServer) makeHandler(method string, handler handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Проверяем, что метод соответствует ожидаемому для данного обработчика
+		if r.Method != method {
+			NewErrorResponse(http.StatusMethodNotAllowed, "invalid request method").write(w)
+			return
+		}
+
+		// Ограничиваем размер тела запроса
+		r.Body = http.MaxBytesReader(w, r.Body, s.MaxBodySize)
+
+		// Вызываем сам обработчик
+		result, err := handler(r)
+		if err != nil {
+			var errorResponse *ErrorResponse
+
+			if !errors.As(err, &errorResponse) {
+				// Если обработчик вернул неизвестную ошибку, то делаем свою ошибку с кодом 500
+				errorResponse = NewErrorResponse(http.StatusInternalServerError, "internal error")
+
+				// Заодно логируем эту ошибку
+				log.Println("internal error:", err)
+			}
+
+			// Отправляем ответ
+			errorResponse.write(w)
+			return
+		}
+
+		writeResponse(w, &Response{result}, http.StatusOK)
+	})
+}
+
+// writeResponse преобразует ответ в JSON и отправляет его клиенту
+func writeResponse(w http.ResponseWriter, data any, status int) {
+	// Сообщаем, что наш ответ будет в формате JSON
+	w.Header().Set("Content-Type", "application/json")
+
+	// Отправляем код ответа
+	w.WriteHeader(status)
+
+	// Преобразуем ответ в JSON и отправляем его
+	encoder := json.NewEncoder(w)
+	err := encoder.Encode(data)
+	if err != nil {
+		log.Println("error encoding response:", err)
+		return
+	}
+}
+
+// Простейший middleware для логирования запросов
+func logger(handler http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Засекаем время начала обработки запроса
+		start := time.Now()
+
+		// Обрабатываем запрос
+		handler.ServeHTTP(w, r)
+
+		// Выводим собранную информацию
+		log.Println(r.Method, r.URL.Path, "-", time.Since(start))
+	}
+}
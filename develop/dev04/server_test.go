@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// request выполняет запрос к серверу и возвращает код ответа и разобранное тело
+func request(t *testing.T, handler http.Handler, method, target, body string) (int, map[string]any) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	result := make(map[string]any)
+	err := json.Unmarshal(recorder.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatalf("invalid response for %s %s: %s", method, target, recorder.Body.String())
+	}
+
+	return recorder.Code, result
+}
+
+func TestAnagramServer(t *testing.T) {
+	server := NewAnagramServer(NewAnagramIndex(Russian), Russian)
+	server.MaxBodySize = 1024
+	server.MaxPageSize = 1
+	mux := server.Mux()
+
+	code, body := request(t, mux, http.MethodPost, "/dictionary", "# словарь\nпятак\nлисток\nпятка\nслиток\nарбуз\n")
+	if code != http.StatusOK || body["result"].(map[string]any)["groups"] != 2.0 {
+		t.Errorf("unexpected upload response: %d %v", code, body)
+	}
+
+	code, body = request(t, mux, http.MethodPost, "/dictionary?mode=append", "тяпка\n")
+	if code != http.StatusOK || body["result"].(map[string]any)["words"] != 6.0 {
+		t.Errorf("unexpected append response: %d %v", code, body)
+	}
+
+	code, body = request(t, mux, http.MethodGet, "/anagrams?word=Капят", "")
+	result := body["result"].(map[string]any)
+	if code != http.StatusOK || result["key"] != "пятак" || len(result["words"].([]any)) != 3 {
+		t.Errorf("unexpected lookup response: %d %v", code, body)
+	}
+
+	code, body = request(t, mux, http.MethodGet, "/anagrams?word=банан", "")
+	if code != http.StatusNotFound || body["error"] == nil {
+		t.Errorf("unexpected response for unknown word: %d %v", code, body)
+	}
+
+	// Вторая страница из одной группы при limit, ограниченном сервером
+	code, body = request(t, mux, http.MethodGet, "/groups?offset=1&limit=10", "")
+	result = body["result"].(map[string]any)
+	groups := result["groups"].([]any)
+	if code != http.StatusOK || result["total"] != 2.0 || len(groups) != 1 ||
+		groups[0].(map[string]any)["key"] != "листок" {
+		t.Errorf("unexpected groups response: %d %v", code, body)
+	}
+
+	code, _ = request(t, mux, http.MethodGet, "/groups?offset=-1", "")
+	if code != http.StatusBadRequest {
+		t.Errorf("unexpected code for invalid offset: %d", code)
+	}
+
+	code, _ = request(t, mux, http.MethodPost, "/dictionary", strings.Repeat("слово\n", 1000))
+	if code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected code for large dictionary: %d", code)
+	}
+
+	code, _ = request(t, mux, http.MethodGet, "/dictionary", "")
+	if code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected code for invalid method: %d", code)
+	}
+
+	// Неудачная загрузка не должна менять словарь
+	code, body = request(t, mux, http.MethodGet, "/groups", "")
+	if code != http.StatusOK || body["result"].(map[string]any)["total"] != 2.0 {
+		t.Errorf("dictionary changed after failed upload: %d %v", code, body)
+	}
+}
+
+// permutations возвращает все перестановки букв слова
+func permutations(word string) []string {
+	letters := []rune(word)
+	if len(letters) <= 1 {
+		return []string{word}
+	}
+
+	result := make([]string, 0)
+	for i, r := range letters {
+		rest := string(letters[:i]) + string(letters[i+1:])
+		for _, p := range permutations(rest) {
+			result = append(result, string(r)+p)
+		}
+	}
+
+	return result
+}
+
+func TestAnagramServer_ConcurrentAppend(t *testing.T) {
+	words := permutations("абвгдеж")
+	server := NewAnagramServer(NewAnagramIndex(Russian), Russian)
+	mux := server.Mux()
+
+	code, _ := request(t, mux, http.MethodPost, "/dictionary", words[0])
+	if code != http.StatusOK {
+		t.Fatalf("unexpected upload code: %d", code)
+	}
+
+	// Пока идёт дополнение словаря, запросы должны видеть группу либо целиком старой, либо целиком новой
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				code, body := request(t, mux, http.MethodGet, "/anagrams?word=абвгдеж", "")
+				if code != http.StatusOK {
+					t.Errorf("unexpected lookup code: %d", code)
+					return
+				}
+
+				n := len(body["result"].(map[string]any)["words"].([]any))
+				if n != 1 && n != len(words) {
+					t.Errorf("lookup observed partially appended dictionary: %d words", n)
+					return
+				}
+			}
+		}()
+	}
+
+	code, _ = request(t, mux, http.MethodPost, "/dictionary?mode=append", strings.Join(words[1:], "\n"))
+	close(done)
+	wg.Wait()
+
+	if code != http.StatusOK || server.Index().Len() != len(words) {
+		t.Errorf("unexpected append result: %d, %d words", code, server.Index().Len())
+	}
+}
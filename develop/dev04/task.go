@@ -1,10 +1,20 @@
 package main
 
 import (
-	"crypto/md5"
-	"encoding/binary"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
 	"sort"
-	"unicode"
+	"strconv"
+	"strings"
 )
 
 /*
@@ -26,39 +36,252 @@ import (
 Программа должна проходить все тесты. Код должен проходить проверки go vet и golint.
 */
 
-// Hash преобразует слово, поданное на вход, в некоторое число, которое зависит лишь от количества вхождений каждой из
-// букв русского алфавита в данное слово.
-func Hash(word string) uint16 {
-	counts := [32]byte{}
+var (
+	// Алфавит, по правилам которого определяются сигнатуры слов
+	language = flag.String("lang", "ru", "alphabet: ru, uk, en or any")
 
-	for _, r := range []rune(word) {
-		// Делаем нашу хэш-функцию независящей от регистра
-		r = unicode.ToLower(r)
+	// Строки словаря, начинающиеся с этого префикса, считаются комментариями. Пустая строка отключает комментарии.
+	commentPrefix = flag.String("comment", "#", "prefix of comment lines")
 
-		// Считаем е и ё одной буквой, поскольку в unicode код ё находится вне диапазона а-я, создавая нам излишние
-		// проблемы. Заодно получим алфавит из красивого числа букв - 32.
-		if r == 'ё' {
-			r = 'е'
+	// Индекс поля (поля разделяются пробельными символами), в котором находится слово
+	wordField = flag.Int("field", 0, "index of the field with a word")
+
+	// Индекс поля с частотой слова, -1 если в словаре нет частот
+	frequencyField = flag.Int("freq", -1, "index of the field with word frequency")
+
+	// Слова с частотой ниже заданной пропускаются. Учитывается только вместе с -freq.
+	minFrequency = flag.Float64("min-freq", 0, "skip words with lower frequency")
+
+	// Группы, в которых меньше слов, не выводятся
+	minGroupSize = flag.Int("min-size", 2, "minimum number of words in a group")
+
+	// Порядок вывода групп
+	sortBy = flag.String("sort", "key", "sort groups by key or size")
+
+	// Формат вывода
+	outputFormat = flag.String("format", "text", "output format: text, json or csv")
+
+	// Вместо точных анаграмм искать слова, наборы букв которых отличаются не больше чем на заданное количество правок
+	nearDistance = flag.Int("near", 0, "group words whose letters differ by at most N edits")
+
+	// Вместо вывода групп запустить HTTP сервер на заданном адресе
+	serveAddr = flag.String("serve", "", "start HTTP server on the given address")
+
+	// Файл с сохранённым индексом для сервера. Если файл есть, словарь не читается, иначе индекс строится по словарю
+	// и сохраняется в этот файл.
+	indexFile = flag.String("index", "", "with -serve, load the index from FILE or build it from the dictionary and save")
+)
+
+// DictionaryOptions описывает формат файла словаря
+type DictionaryOptions struct {
+	// CommentPrefix - префикс строк-комментариев, пустая строка отключает комментарии
+	CommentPrefix string
+
+	// WordField - индекс поля со словом
+	WordField int
+
+	// FrequencyField - индекс поля с частотой слова или -1, если частот в словаре нет
+	FrequencyField int
+
+	// MinFrequency - минимальная частота слова, при которой оно попадает в результат
+	MinFrequency float64
+}
+
+// ReadDictionary считывает слова из reader, по одному слову в строке. Пустые строки и комментарии пропускаются,
+// повторяющиеся слова учитываются только один раз.
+func ReadDictionary(reader io.Reader, options DictionaryOptions) ([]string, error) {
+	scanner := bufio.NewScanner(reader)
+	words := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Пропускаем пустые строки и комментарии
+		if line == "" || (options.CommentPrefix != "" && strings.HasPrefix(line, options.CommentPrefix)) {
+			continue
 		}
 
-		// Игнорируем всё, что находится вне диапазона а-я
-		if r < 'а' || r > 'я' {
+		fields := strings.Fields(line)
+		if options.WordField >= len(fields) {
+			return nil, fmt.Errorf("line %d: no field %d", lineNumber, options.WordField)
+		}
+
+		// Если в словаре есть частоты, отбрасываем редкие слова
+		if options.FrequencyField >= 0 {
+			if options.FrequencyField >= len(fields) {
+				return nil, fmt.Errorf("line %d: no field %d", lineNumber, options.FrequencyField)
+			}
+
+			frequency, err := strconv.ParseFloat(fields[options.FrequencyField], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid frequency: %w", lineNumber, err)
+			}
+
+			if frequency < options.MinFrequency {
+				continue
+			}
+		}
+
+		word := fields[options.WordField]
+		if seen[word] {
 			continue
 		}
 
-		// Количество текущей буквы сохраняем в соответствующую ей ячейку массива
-		counts[r-'а'] += 1
+		seen[word] = true
+		words = append(words, word)
+	}
+
+	return words, scanner.Err()
+}
+
+// SortGroups упорядочивает группы по ключу ("key") или по убыванию количества слов ("size"). Группы одинакового
+// размера упорядочиваются по ключу.
+func SortGroups(groups []*Group, by string) error {
+	switch by {
+	case "key":
+		sort.SliceStable(groups, func(i, j int) bool {
+			return groups[i].Key < groups[j].Key
+		})
+	case "size":
+		sort.SliceStable(groups, func(i, j int) bool {
+			if groups[i].Words.Len() != groups[j].Words.Len() {
+				return groups[i].Words.Len() > groups[j].Words.Len()
+			}
+
+			return groups[i].Key < groups[j].Key
+		})
+	default:
+		return fmt.Errorf("unknown sort order \"%s\"", by)
+	}
+
+	return nil
+}
+
+// SortClusters упорядочивает кластеры так же, как SortGroups упорядочивает группы
+func SortClusters(clusters []*Cluster, by string) error {
+	switch by {
+	case "key":
+		sort.SliceStable(clusters, func(i, j int) bool {
+			return clusters[i].Key < clusters[j].Key
+		})
+	case "size":
+		sort.SliceStable(clusters, func(i, j int) bool {
+			if len(clusters[i].Words) != len(clusters[j].Words) {
+				return len(clusters[i].Words) > len(clusters[j].Words)
+			}
+
+			return clusters[i].Key < clusters[j].Key
+		})
+	default:
+		return fmt.Errorf("unknown sort order \"%s\"", by)
+	}
+
+	return nil
+}
+
+// WriteClusters записывает кластеры в writer в тех же форматах, что и WriteGroups. В текстовом формате после каждого
+// слова в скобках указывается его расстояние от ключа, в CSV выводится по строке на слово: "key,word,distance".
+func WriteClusters(writer io.Writer, clusters []*Cluster, format string) error {
+	switch format {
+	case "text":
+		w := bufio.NewWriter(writer)
+		for _, cluster := range clusters {
+			words := make([]string, len(cluster.Words))
+			for i, word := range cluster.Words {
+				words[i] = fmt.Sprintf("%s(%d)", word.Word, word.Distance)
+			}
+
+			_, err := fmt.Fprintf(w, "%s: %s\n", cluster.Key, strings.Join(words, " "))
+			if err != nil {
+				return err
+			}
+		}
+
+		return w.Flush()
+
+	case "json":
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(clusters)
+
+	case "csv":
+		w := csv.NewWriter(writer)
+		err := w.Write([]string{"key", "word", "distance"})
+		if err != nil {
+			return err
+		}
+
+		for _, cluster := range clusters {
+			for _, word := range cluster.Words {
+				err = w.Write([]string{cluster.Key, word.Word, strconv.Itoa(word.Distance)})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		w.Flush()
+		return w.Error()
+
+	default:
+		return fmt.Errorf("unknown output format \"%s\"", format)
 	}
+}
 
-	// Чтобы вместо разреженного массива counts получить что-то более "красивое" и случайное, пропустим его содержимое
-	// через функцию md5.
-	h := md5.New()
-	h.Write(counts[:])
+// WriteGroups записывает группы в writer в одном из форматов:
+// - text: по строке на группу, "ключ: слово слово ..."
+// - json: массив объектов {"key": ..., "words": [...]}
+// - csv: строки "key,size,words", где слова перечислены через пробел
+func WriteGroups(writer io.Writer, groups []*Group, format string) error {
+	switch format {
+	case "text":
+		w := bufio.NewWriter(writer)
+		for _, group := range groups {
+			_, err := fmt.Fprintf(w, "%s: %s\n", group.Key, strings.Join(group.Words, " "))
+			if err != nil {
+				return err
+			}
+		}
+
+		return w.Flush()
 
-	// Поскольку каждый байт, поданный на вход md5, влияет на каждый байт на выходе md5, то мы можем безболезненно
-	// отбросить любую часть хэша. Для такой простой задачи нам хватит и двух байт, которые обеспечат нам в лучшем
-	// случае 2^16 возможных групп анаграмм.
-	return binary.LittleEndian.Uint16(h.Sum(nil)[:2])
+	case "json":
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(groups)
+
+	case "csv":
+		w := csv.NewWriter(writer)
+		err := w.Write([]string{"key", "size", "words"})
+		if err != nil {
+			return err
+		}
+
+		for _, group := range groups {
+			err = w.Write([]string{group.Key, strconv.Itoa(group.Words.Len()), strings.Join(group.Words, " ")})
+			if err != nil {
+				return err
+			}
+		}
+
+		w.Flush()
+		return w.Error()
+
+	default:
+		return fmt.Errorf("unknown output format \"%s\"", format)
+	}
+}
+
+// Signature - каноническое представление набора букв слова: буквы, отсортированные по возрастанию. Слова являются
+// анаграммами тогда и только тогда, когда их сигнатуры совпадают, поэтому, в отличие от хэша, сигнатура не допускает
+// коллизий.
+type Signature string
+
+// Hash преобразует слово, поданное на вход, в сигнатуру, которая зависит лишь от количества вхождений каждой из букв
+// русского алфавита в данное слово.
+func Hash(word string) Signature {
+	return Russian.Hash(word)
 }
 
 // WordContainer - обёртка над []string, реализующая sort.Interface.
@@ -76,40 +299,215 @@ func (w WordContainer) Swap(i, j int) {
 	w[i], w[j] = w[j], w[i]
 }
 
-// GroupAnagrams группирует слова из words
+// GroupAnagrams группирует слова из words, считая их словами на русском языке
 func GroupAnagrams(words []string) *map[string][]string {
-	// Создадим две временные мапы:
-	// - хэш слова -> список слов (чтобы непосредственно группировать слова)
-	// - хэш слова -> первое слово, из которого получен данный хэш (чтобы в итоге восстановить слово по хэшу)
-	groups := make(map[uint16]WordContainer)
-	reverse := make(map[uint16]string)
+	return Russian.GroupAnagrams(words)
+}
+
+// GroupAnagrams группирует слова из words, определяя сигнатуры слов по правилам алфавита a
+func (a *Alphabet) GroupAnagrams(words []string) *map[string][]string {
+	result := make(map[string][]string)
+
+	for _, group := range a.Groups(words) {
+		// Убираем группы, где меньше двух слов
+		if group.Words.Len() < 2 {
+			continue
+		}
+
+		result[group.Key] = group.Words
+	}
+
+	return &result
+}
+
+// Group - множество слов, являющихся анаграммами друг друга
+type Group struct {
+	// Key - первое встретившееся в словаре слово из множества
+	Key string `json:"key"`
+
+	// Signature - общая сигнатура всех слов группы
+	Signature Signature `json:"-"`
+
+	// Words - слова группы, отсортированные по возрастанию
+	Words WordContainer `json:"words"`
+}
+
+// Groups группирует слова из words по сигнатурам. В отличие от GroupAnagrams, возвращает все группы, включая группы
+// из одного слова, в порядке первого появления их ключей в словаре.
+func (a *Alphabet) Groups(words []string) []*Group {
+	// Сигнатура слова -> группа, в которую попадают слова с этой сигнатурой
+	index := make(map[Signature]*Group)
+	groups := make([]*Group, 0)
 
 	for _, word := range words {
 		// Определяем, в какую группу попадёт слово
-		h := Hash(word)
+		h := a.Hash(word)
 
-		// Создаём новую группу с нашим словом или добавляем его в одну из существующих
-		if _, ok := groups[h]; ok {
-			groups[h] = append(groups[h], word)
-		} else {
-			groups[h] = []string{word}
-			reverse[h] = word
+		// Создаём новую группу с нашим словом в качестве ключа или добавляем его в одну из существующих
+		group, ok := index[h]
+		if !ok {
+			group = &Group{Key: word, Signature: h}
+			index[h] = group
+			groups = append(groups, group)
 		}
+
+		group.Words = append(group.Words, word)
 	}
 
-	// Объединяем две предыдущие мапы в одну
-	result := make(map[string][]string)
+	// Сортируем слова в каждой группе
+	for _, group := range groups {
+		sort.Sort(group.Words)
+	}
 
-	for h, key := range reverse {
-		// Убираем группы, где меньше двух слов
-		if groups[h].Len() < 2 {
-			continue
+	return groups
+}
+
+// openInput открывает файл словаря с названием name. Если название не задано или равно "-", используется stdin.
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "" || name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	return os.Open(name)
+}
+
+// run выполняет программу, возвращая ошибку вместо завершения процесса
+func run() error {
+	alphabet, ok := Alphabets[*language]
+	if !ok {
+		return fmt.Errorf("unknown alphabet \"%s\"", *language)
+	}
+
+	if *minGroupSize < 1 {
+		return errors.New("minimum group size must be positive")
+	}
+
+	if *serveAddr != "" {
+		index, err := serverIndex(alphabet)
+		if err != nil {
+			return err
 		}
 
-		// Сортируем слова в каждой группе и добавляем группу в мапу
-		sort.Sort(groups[h])
-		result[key] = groups[h]
+		return serve(index, alphabet)
 	}
 
-	return &result
+	if *indexFile != "" {
+		return errors.New("-index can only be used with -serve")
+	}
+
+	words, err := readWords(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *nearDistance > 0 {
+		return writeNearAnagrams(alphabet, words)
+	}
+
+	// Группируем слова и оставляем только достаточно большие группы
+	groups := make([]*Group, 0)
+	for _, group := range alphabet.Groups(words) {
+		if group.Words.Len() >= *minGroupSize {
+			groups = append(groups, group)
+		}
+	}
+
+	err = SortGroups(groups, *sortBy)
+	if err != nil {
+		return err
+	}
+
+	return WriteGroups(os.Stdout, groups, *outputFormat)
+}
+
+// readWords считывает слова из словаря с названием name в формате, заданном флагами
+func readWords(name string) ([]string, error) {
+	input, err := openInput(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer input.Close()
+
+	return ReadDictionary(input, DictionaryOptions{
+		CommentPrefix:  *commentPrefix,
+		WordField:      *wordField,
+		FrequencyField: *frequencyField,
+		MinFrequency:   *minFrequency,
+	})
+}
+
+// serverIndex возвращает индекс, с которым стартует сервер. С -index индекс загружается из файла или строится по
+// словарю и сохраняется. Сервер может стартовать и без словаря: его можно загрузить позже через API.
+func serverIndex(alphabet *Alphabet) (*AnagramIndex, error) {
+	if *indexFile == "" {
+		index := NewAnagramIndex(alphabet)
+		if flag.Arg(0) == "" {
+			return index, nil
+		}
+
+		words, err := readWords(flag.Arg(0))
+		for _, word := range words {
+			index.Add(word)
+		}
+
+		return index, err
+	}
+
+	// Без словаря пустой индекс не сохраняем, иначе при следующем запуске со словарём загрузился бы пустой индекс
+	if flag.Arg(0) == "" {
+		if _, err := os.Stat(*indexFile); errors.Is(err, fs.ErrNotExist) {
+			return NewAnagramIndex(alphabet), nil
+		}
+	}
+
+	index, loaded, err := OpenAnagramIndex(*indexFile, alphabet, func() ([]string, error) {
+		return readWords(flag.Arg(0))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if loaded {
+		log.Printf("loaded %d words from index %s", index.Len(), *indexFile)
+	} else {
+		log.Printf("saved %d words to index %s", index.Len(), *indexFile)
+	}
+
+	return index, nil
+}
+
+// writeNearAnagrams выводит кластеры похожих наборов букв вместо точных групп анаграмм
+func writeNearAnagrams(alphabet *Alphabet, words []string) error {
+	clusters := make([]*Cluster, 0)
+	for _, cluster := range alphabet.NearAnagrams(words, *nearDistance) {
+		if len(cluster.Words) >= *minGroupSize {
+			clusters = append(clusters, cluster)
+		}
+	}
+
+	err := SortClusters(clusters, *sortBy)
+	if err != nil {
+		return err
+	}
+
+	return WriteClusters(os.Stdout, clusters, *outputFormat)
+}
+
+// serve запускает HTTP сервер для поиска анаграмм по индексу index
+func serve(index *AnagramIndex, alphabet *Alphabet) error {
+	server := NewAnagramServer(index, alphabet)
+	log.Println("listening on", *serveAddr)
+
+	return http.ListenAndServe(*serveAddr, logger(server.Mux()))
+}
+
+func main() {
+	flag.Parse()
+
+	err := run()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
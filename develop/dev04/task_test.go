@@ -1,7 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +33,130 @@ func TestGroupAnagrams(t *testing.T) {
 		}
 	}
 }
+
+// generateDictionary создаёт детерминированный словарь из n случайных русских слов. Часть слов - перестановки букв
+// уже созданных слов, чтобы в словаре были настоящие группы анаграмм.
+func generateDictionary(n int) []string {
+	alphabet := []rune("абвгдежзийклмнопрстуфхцчшщъыьэюя")
+	rng := rand.New(rand.NewSource(1))
+	words := make([]string, 0, n)
+
+	for len(words) < n {
+		if len(words) > 0 && rng.Intn(4) == 0 {
+			word := []rune(words[rng.Intn(len(words))])
+			rng.Shuffle(len(word), func(i, j int) {
+				word[i], word[j] = word[j], word[i]
+			})
+
+			words = append(words, string(word))
+			continue
+		}
+
+		word := make([]rune, 3+rng.Intn(7))
+		for i := range word {
+			word[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+
+		words = append(words, string(word))
+	}
+
+	return words
+}
+
+func TestGroupAnagrams_NoCollisions(t *testing.T) {
+	words := generateDictionary(100000)
+
+	// Эталонная группировка по полному вектору количества букв
+	expected := make(map[[32]int][]string)
+	for _, word := range words {
+		counts := [32]int{}
+		for _, r := range word {
+			counts[r-'а']++
+		}
+
+		expected[counts] = append(expected[counts], word)
+	}
+
+	expectedGroups := 0
+	for _, group := range expected {
+		if len(group) > 1 {
+			expectedGroups++
+		}
+	}
+
+	groups := *GroupAnagrams(words)
+	if len(groups) != expectedGroups {
+		t.Fatalf("unexpected number of groups: %d (expected %d)", len(groups), expectedGroups)
+	}
+
+	for key, group := range groups {
+		counts := [32]int{}
+		for _, r := range key {
+			counts[r-'а']++
+		}
+
+		reference := append([]string{}, expected[counts]...)
+		sort.Strings(reference)
+
+		if !reflect.DeepEqual(group, reference) {
+			t.Fatalf("group %s mixes words that are not anagrams: %v (expected %v)", key, group, reference)
+		}
+	}
+}
+
+func TestReadDictionary(t *testing.T) {
+	const dictionary = "# частотный словарь\n\nпятак 10\nпятка 3\n  тяпка 1\nпятак 4\nарбуз 2\n"
+
+	words, err := ReadDictionary(strings.NewReader(dictionary), DictionaryOptions{
+		CommentPrefix:  "#",
+		FrequencyField: 1,
+		MinFrequency:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"пятак", "пятка", "арбуз"}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("unexpected words: %v (expected %v)", words, expected)
+	}
+
+	_, err = ReadDictionary(strings.NewReader("пятак много\n"), DictionaryOptions{FrequencyField: 1})
+	if err == nil {
+		t.Errorf("expected error for invalid frequency")
+	}
+}
+
+func TestWriteGroups(t *testing.T) {
+	groups := Russian.Groups([]string{"тяпка", "пятак", "листок", "слиток", "пятка", "арбуз"})
+	err := SortGroups(groups, "size")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"text": "тяпка: пятак пятка тяпка\nлисток: листок слиток\nарбуз: арбуз\n",
+		"csv":  "key,size,words\nтяпка,3,пятак пятка тяпка\nлисток,2,листок слиток\nарбуз,1,арбуз\n",
+		"json": `[{"key":"тяпка","words":["пятак","пятка","тяпка"]},{"key":"листок","words":["листок","слиток"]},` +
+			`{"key":"арбуз","words":["арбуз"]}]`,
+	}
+
+	for format, expected := range tests {
+		buf := &bytes.Buffer{}
+		err := WriteGroups(buf, groups, format)
+		if err != nil {
+			t.Errorf("error for format %s: %s", format, err)
+		}
+
+		actual := buf.String()
+		if format == "json" {
+			compact := &bytes.Buffer{}
+			_ = json.Compact(compact, buf.Bytes())
+			actual = compact.String()
+		}
+
+		if actual != expected {
+			t.Errorf("unexpected output for format %s:\n%s", format, actual)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// acNode - узел автомата Ахо-Корасик
+type acNode struct {
+	// Переходы по следующей руне
+	next map[rune]int32
+
+	// Суффиксная ссылка: узел, соответствующий самому длинному собственному суффиксу строки этого узла
+	fail int32
+
+	// Ближайший по суффиксным ссылкам конечный узел (не считая этого), или -1
+	output int32
+
+	// Длина образца в рунах, если узел конечный, иначе 0
+	length int
+}
+
+// AhoCorasick ищет в строке сразу все образцы из набора за один проход. Сравнение идёт по рунам, поэтому при
+// игнорировании регистра смещения найденных фрагментов остаются правильными, даже если свёртка регистра меняет длину
+// руны в байтах. Автомат не изменяется после построения и может использоваться из нескольких горутин.
+type AhoCorasick struct {
+	nodes []acNode
+
+	// Игнорировать регистр?
+	fold bool
+
+	// Есть ли среди образцов пустая строка
+	hasEmpty bool
+
+	// Длина самого длинного образца в рунах
+	maxLength int
+}
+
+// NewAhoCorasick строит автомат для набора образцов. Если fold, регистр букв не учитывается.
+func NewAhoCorasick(patterns []string, fold bool) *AhoCorasick {
+	a := &AhoCorasick{
+		nodes: []acNode{{output: -1}},
+		fold:  fold,
+	}
+
+	// Строим бор из образцов
+	for _, pattern := range patterns {
+		if pattern == "" {
+			a.hasEmpty = true
+			continue
+		}
+
+		state, length := int32(0), 0
+		for _, r := range pattern {
+			if fold {
+				r = foldRune(r)
+			}
+
+			next, ok := a.nodes[state].next[r]
+			if !ok {
+				if a.nodes[state].next == nil {
+					a.nodes[state].next = map[rune]int32{}
+				}
+
+				next = int32(len(a.nodes))
+				a.nodes = append(a.nodes, acNode{output: -1})
+				a.nodes[state].next[r] = next
+			}
+
+			state = next
+			length++
+		}
+
+		a.nodes[state].length = length
+		if length > a.maxLength {
+			a.maxLength = length
+		}
+	}
+
+	// Обходом в ширину вычисляем суффиксные ссылки и ссылки на конечные узлы
+	queue := make([]int32, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for r, child := range a.nodes[state].next {
+			fail := a.nodes[state].fail
+			for {
+				if next, ok := a.nodes[fail].next[r]; ok {
+					fail = next
+					break
+				}
+
+				if fail == 0 {
+					break
+				}
+
+				fail = a.nodes[fail].fail
+			}
+
+			a.nodes[child].fail = fail
+			if a.nodes[fail].length > 0 {
+				a.nodes[child].output = fail
+			} else {
+				a.nodes[child].output = a.nodes[fail].output
+			}
+
+			queue = append(queue, child)
+		}
+	}
+
+	return a
+}
+
+// step выполняет переход автомата из состояния state по руне r
+func (a *AhoCorasick) step(state int32, r rune) int32 {
+	for {
+		if next, ok := a.nodes[state].next[r]; ok {
+			return next
+		}
+
+		if state == 0 {
+			return 0
+		}
+
+		state = a.nodes[state].fail
+	}
+}
+
+// FindAll находит в text непересекающиеся вхождения образцов так же, как grep: из вхождений, начинающихся левее,
+// выбирается самое длинное, следующее ищется после его конца. Вхождения, для которых accept возвращает false,
+// пропускаются. Если вхождений нет, возвращается nil; пустой образец даёт пустой, но не nil результат.
+func (a *AhoCorasick) FindAll(text string, accept func(start, end int) bool) []Span {
+	// Самый дальний конец вхождения для каждого начала; выделяется при первом найденном вхождении
+	var bestEnd []int
+
+	// Смещения в байтах последних maxLength+1 рун, чтобы по длине образца найти начало вхождения
+	starts := make([]int, a.maxLength+1)
+
+	state := int32(0)
+	for i, offset := 0, 0; offset < len(text); i++ {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		starts[i%len(starts)] = offset
+
+		if a.fold {
+			r = foldRune(r)
+		}
+
+		state = a.step(state, r)
+		end := offset + size
+		offset = end
+
+		// Перебираем все образцы, которые заканчиваются на этой руне
+		node := state
+		if a.nodes[node].length == 0 {
+			node = a.nodes[node].output
+		}
+
+		for ; node >= 0; node = a.nodes[node].output {
+			start := starts[(i+1-a.nodes[node].length)%len(starts)]
+			if !accept(start, end) {
+				continue
+			}
+
+			if bestEnd == nil {
+				bestEnd = make([]int, len(text))
+			}
+
+			if end > bestEnd[start] {
+				bestEnd[start] = end
+			}
+		}
+	}
+
+	if bestEnd == nil {
+		if a.hasEmpty && accept(0, 0) {
+			return []Span{}
+		}
+
+		return nil
+	}
+
+	var spans []Span
+	last := 0
+	for start, end := range bestEnd {
+		if end > 0 && start >= last {
+			spans = append(spans, Span{Start: start, End: end})
+			last = end
+		}
+	}
+
+	return spans
+}
+
+// foldRune приводит руну к единому представителю её класса эквивалентности по регистру (наименьшей руне класса),
+// так что, например, 'K', 'k' и знак кельвина 'K' сворачиваются в одну руну
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		if 'a' <= r && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+
+		return r
+	}
+
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+
+	return min
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAhoCorasick_FindAll(t *testing.T) {
+	acceptAll := func(int, int) bool {
+		return true
+	}
+
+	tests := []struct {
+		patterns []string
+		fold     bool
+		text     string
+		expected []Span
+	}{
+		{
+			patterns: []string{"he", "she", "his", "hers"},
+			text:     "ushers",
+			expected: []Span{{Start: 1, End: 4}},
+		},
+		{
+			// Самое левое вхождение выигрывает, даже если справа начинается более длинное
+			patterns: []string{"ab", "bcd", "cd"},
+			text:     "abcd",
+			expected: []Span{{Start: 0, End: 2}, {Start: 2, End: 4}},
+		},
+		{
+			// Из вхождений с одинаковым началом выбирается самое длинное
+			patterns: []string{"a", "abc", "ab"},
+			text:     "xabcab",
+			expected: []Span{{Start: 1, End: 4}, {Start: 4, End: 6}},
+		},
+		{
+			patterns: []string{"привет"},
+			fold:     true,
+			text:     "Всем ПРИВЕТ и Привет",
+			expected: []Span{{Start: 9, End: 21}, {Start: 25, End: 37}},
+		},
+		{
+			// Знак кельвина занимает 3 байта, но сворачивается в ту же руну, что и "k"
+			patterns: []string{"kelvin"},
+			fold:     true,
+			text:     "Kelvin",
+			expected: []Span{{Start: 0, End: 8}},
+		},
+		{
+			patterns: []string{"zzz"},
+			text:     "abc",
+			expected: nil,
+		},
+		{
+			patterns: []string{"", "zzz"},
+			text:     "abc",
+			expected: []Span{},
+		},
+	}
+
+	for _, c := range tests {
+		actual := NewAhoCorasick(c.patterns, c.fold).FindAll(c.text, acceptAll)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%v in %q: unexpected spans %#v (expected %#v)", c.patterns, c.text, actual, c.expected)
+		}
+	}
+}
+
+func TestAhoCorasick_FindAllAccept(t *testing.T) {
+	a := NewAhoCorasick([]string{"foo", "foobar"}, false)
+
+	// Отклонённое длинное вхождение не мешает найти короткое с тем же началом
+	actual := a.FindAll("foobar", func(start, end int) bool {
+		return end-start == 3
+	})
+
+	if !reflect.DeepEqual(actual, []Span{{Start: 0, End: 3}}) {
+		t.Errorf("unexpected spans: %v", actual)
+	}
+}
+
+// blocklist создаёт набор из n различных образцов
+func blocklist(n int) []string {
+	patterns := make([]string, n)
+	for i := range patterns {
+		patterns[i] = fmt.Sprintf("blocked-%d.example.com", i)
+	}
+
+	return patterns
+}
+
+func BenchmarkAhoCorasick(b *testing.B) {
+	a := NewAhoCorasick(blocklist(5000), true)
+	line := strings.Repeat("GET /index.html host=allowed.example.com ", 5) + "host=blocked-4999.example.com"
+	accept := func(int, int) bool {
+		return true
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if a.FindAll(line, accept) == nil {
+			b.Fatal("pattern not found")
+		}
+	}
+}
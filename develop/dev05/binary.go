@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// binaryMode - значение флага --binary-files: как поступать с двоичными файлами
+type binaryMode string
+
+const (
+	// Искать как обычно, но вместо строк выводить только сообщение о том, что файл содержит совпадение
+	binaryBinary binaryMode = "binary"
+
+	// Считать, что в двоичном файле ничего не найдено
+	binaryWithoutMatch binaryMode = "without-match"
+
+	// Обрабатывать двоичный файл как текст
+	binaryText binaryMode = "text"
+)
+
+func (m *binaryMode) String() string {
+	return string(*m)
+}
+
+func (m *binaryMode) Set(value string) error {
+	switch mode := binaryMode(value); mode {
+	case binaryBinary, binaryWithoutMatch, binaryText:
+		*m = mode
+	default:
+		return fmt.Errorf("unknown binary-files type %q", value)
+	}
+
+	return nil
+}
+
+// binaryFilesFlag регистрирует флаг --binary-files
+func binaryFilesFlag(name, usage string) *binaryMode {
+	m := binaryBinary
+	flag.Var(&m, name, usage)
+	return &m
+}
+
+// lineReaderBufferSize - размер буфера чтения; первое чтение в буфер считается первым блоком файла
+const lineReaderBufferSize = 64 << 10
+
+// lineReader построчно читает данные так же, как bufio.Scanner с bufio.ScanLines, но без ограничения на длину строки:
+// длинная строка собирается из нескольких заполнений буфера.
+type lineReader struct {
+	reader *bufio.Reader
+
+	// Буфер для строк, которые не поместились в буфер чтения
+	line []byte
+
+	// Последняя прочитанная строка без перевода строки, сам перевод строки и размер строки вместе с ним
+	text string
+	eol  string
+	size int
+
+	err error
+}
+
+func newLineReader(reader io.Reader) *lineReader {
+	return &lineReader{reader: bufio.NewReaderSize(reader, lineReaderBufferSize)}
+}
+
+// Scan читает следующую строку. Возвращает false в конце данных или при ошибке чтения, которую затем вернёт Err.
+func (r *lineReader) Scan() bool {
+	if r.err != nil {
+		return false
+	}
+
+	var data []byte
+
+	r.line = r.line[:0]
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+
+		// Строка поместилась в буфер чтения целиком: копировать её во второй буфер не нужно
+		if len(r.line) == 0 && err != bufio.ErrBufferFull {
+			data = chunk
+		} else {
+			r.line = append(r.line, chunk...)
+			data = r.line
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+
+		if err != nil && err != io.EOF {
+			r.err = err
+			return false
+		}
+
+		if err == io.EOF && len(data) == 0 {
+			return false
+		}
+
+		break
+	}
+
+	r.size = len(data)
+
+	// Как и bufio.ScanLines, отбрасываем "\r" перед "\n" и в конце данных
+	text := data
+	if bytes.HasSuffix(text, []byte("\n")) {
+		text = text[:len(text)-1]
+	}
+
+	if bytes.HasSuffix(text, []byte("\r")) {
+		text = text[:len(text)-1]
+	}
+
+	r.text = string(text)
+	r.eol = string(data[len(text):])
+
+	return true
+}
+
+// Text возвращает последнюю прочитанную строку без перевода строки
+func (r *lineReader) Text() string {
+	return r.text
+}
+
+// EOL возвращает перевод строки, которым заканчивалась последняя прочитанная строка
+func (r *lineReader) EOL() string {
+	return r.eol
+}
+
+// Size возвращает размер последней прочитанной строки в байтах вместе с переводом строки
+func (r *lineReader) Size() int {
+	return r.size
+}
+
+func (r *lineReader) Err() error {
+	return r.err
+}
+
+// BinaryOffset проверяет, двоичные ли данные, как GNU grep: ищет байт NUL в первом прочитанном блоке. Возвращает
+// смещение первого NUL или -1, если данные текстовые. Вызывается до чтения первой строки.
+func (r *lineReader) BinaryOffset() int64 {
+	// Peek заполняет пустой буфер одним чтением; первым блоком считается всё, что оказалось в буфере. Ошибку чтения
+	// bufio.Reader возвращает только один раз, поэтому её нужно запомнить для Scan.
+	if _, err := r.reader.Peek(1); err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+
+		return -1
+	}
+
+	block, _ := r.reader.Peek(r.reader.Buffered())
+	return int64(bytes.IndexByte(block, 0))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLineReader(t *testing.T) {
+	long := strings.Repeat("x", 3*lineReaderBufferSize+17)
+	input := "a\r\n" + long + "\n\nlast\r"
+
+	type line struct {
+		text, eol string
+		size      int
+	}
+
+	expected := []line{
+		{text: "a", eol: "\r\n", size: 3},
+		{text: long, eol: "\n", size: len(long) + 1},
+		{text: "", eol: "\n", size: 1},
+		{text: "last", eol: "\r", size: 5},
+	}
+
+	// Чтение по одному байту проверяет сборку строки из многих неполных заполнений буфера
+	for _, reader := range []*lineReader{
+		newLineReader(strings.NewReader(input)),
+		newLineReader(iotest.OneByteReader(strings.NewReader(input))),
+	} {
+		var actual []line
+		for reader.Scan() {
+			actual = append(actual, line{text: reader.Text(), eol: reader.EOL(), size: reader.Size()})
+		}
+
+		if err := reader.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("unexpected lines: %d lines read (expected %d)", len(actual), len(expected))
+		}
+	}
+
+	// Ошибка чтения при проверке первого блока не теряется
+	reader := newLineReader(iotest.ErrReader(iotest.ErrTimeout))
+	if offset := reader.BinaryOffset(); offset != -1 {
+		t.Errorf("unexpected binary offset: %d", offset)
+	}
+
+	if reader.Scan() || reader.Err() != iotest.ErrTimeout {
+		t.Errorf("unexpected error: %v", reader.Err())
+	}
+}
+
+func TestLineReader_BinaryOffset(t *testing.T) {
+	tests := map[string]int64{
+		"":                -1,
+		"text\nonly\n":    -1,
+		"text\n\x00bin\n": 5,
+		"\x00":            0,
+	}
+
+	for input, expected := range tests {
+		if actual := newLineReader(strings.NewReader(input)).BinaryOffset(); actual != expected {
+			t.Errorf("BinaryOffset(%q) = %d (expected %d)", input, actual, expected)
+		}
+	}
+
+	// NUL за пределами первого блока не делает файл двоичным
+	input := strings.Repeat("text\n", lineReaderBufferSize) + "\x00"
+	if actual := newLineReader(strings.NewReader(input)).BinaryOffset(); actual != -1 {
+		t.Errorf("NUL after the first block: unexpected binary offset %d", actual)
+	}
+}
+
+func TestPrinter_PrintBinary(t *testing.T) {
+	input := "text error\n\x00bin\nmore error\n"
+
+	tests := []struct {
+		printer  *Printer
+		expected string
+	}{
+		{
+			printer:  &Printer{fileName: "b.bin"},
+			expected: "Binary file b.bin matches\n",
+		},
+		{
+			printer:  &Printer{fileName: "b.bin", onlyCount: true},
+			expected: "2\n",
+		},
+		{
+			printer:  &Printer{fileName: "b.bin", binaryFiles: binaryWithoutMatch},
+			expected: "",
+		},
+		{
+			printer:  &Printer{fileName: "b.bin", binaryFiles: binaryWithoutMatch, listNonMatching: true},
+			expected: "b.bin\n",
+		},
+		{
+			printer:  &Printer{fileName: "b.bin", binaryFiles: binaryText, lineNumbers: true},
+			expected: "1:text error\n3:more error\n",
+		},
+		{
+			printer: &Printer{fileName: "b.bin", json: true},
+			expected: `{"type":"begin","data":{"path":{"text":"b.bin"}}}` + "\n" +
+				`{"type":"end","data":{"path":{"text":"b.bin"},"binary_offset":11,"stats":{`,
+		},
+	}
+
+	for _, c := range tests {
+		buf := &strings.Builder{}
+		if _, err := c.printer.Print(&Search{predicate: mustPredicate(t, "error")}, strings.NewReader(input), buf); err != nil {
+			t.Fatal(err)
+		}
+
+		actual := buf.String()
+		if c.printer.json && strings.HasPrefix(actual, c.expected) {
+			continue
+		}
+
+		if actual != c.expected {
+			t.Errorf("unexpected output for %+v: %q (expected %q)", c.printer, actual, c.expected)
+		}
+	}
+}
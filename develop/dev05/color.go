@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// colorMode - значение флага --color: never, always или auto. Флаг можно передать без значения, тогда он означает
+// auto, как в GNU grep.
+type colorMode string
+
+const (
+	colorNever  colorMode = "never"
+	colorAlways colorMode = "always"
+	colorAuto   colorMode = "auto"
+)
+
+func (m *colorMode) String() string {
+	return string(*m)
+}
+
+func (m *colorMode) Set(value string) error {
+	switch value {
+	case "true", "auto", "tty", "if-tty":
+		*m = colorAuto
+	case "always", "yes", "force":
+		*m = colorAlways
+	case "never", "no", "none":
+		*m = colorNever
+	default:
+		return fmt.Errorf("invalid color mode %q", value)
+	}
+
+	return nil
+}
+
+// IsBoolFlag позволяет передавать флаг без значения: --color
+func (m *colorMode) IsBoolFlag() bool {
+	return true
+}
+
+// Enabled решает, нужно ли раскрашивать вывод в file. В режиме auto вывод раскрашивается, только если file -
+// терминал, а переменная TERM не равна "dumb".
+func (m colorMode) Enabled(file *os.File) bool {
+	switch m {
+	case colorAlways:
+		return true
+	case colorAuto:
+		info, err := file.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+	default:
+		return false
+	}
+}
+
+// Colors - SGR-последовательности для раскраски разных частей вывода, в формате переменной GREP_COLORS
+type Colors struct {
+	// Найденный фрагмент в выбранной строке (ms)
+	SelectedMatch string
+
+	// Найденный фрагмент в строке контекста (mc)
+	ContextMatch string
+
+	// Выбранная строка целиком (sl)
+	SelectedLine string
+
+	// Строка контекста целиком (cx)
+	ContextLine string
+
+	// Имя файла (fn)
+	FileName string
+
+	// Номер строки (ln)
+	LineNumber string
+
+	// Смещение в байтах (bn)
+	ByteOffset string
+
+	// Разделители ":" и "-" (se)
+	Separator string
+
+	// Поменять местами sl и cx при -v (rv)
+	Reverse bool
+
+	// Не дописывать EL (\x1b[K) после SGR-последовательностей (ne)
+	NoErase bool
+}
+
+// DefaultColors возвращает цвета GNU grep по умолчанию
+func DefaultColors() *Colors {
+	return &Colors{
+		SelectedMatch: "01;31",
+		ContextMatch:  "01;31",
+		FileName:      "35",
+		LineNumber:    "32",
+		ByteOffset:    "32",
+		Separator:     "36",
+	}
+}
+
+// ParseColors дополняет цвета значениями из строки в формате GREP_COLORS, например "ms=01;32:fn=34:ne".
+// Неизвестные ключи игнорируются, как и в GNU grep.
+func ParseColors(colors *Colors, spec string) *Colors {
+	c := *colors
+
+	for _, item := range strings.Split(spec, ":") {
+		key, value, _ := strings.Cut(item, "=")
+
+		switch key {
+		case "mt":
+			c.SelectedMatch, c.ContextMatch = value, value
+		case "ms":
+			c.SelectedMatch = value
+		case "mc":
+			c.ContextMatch = value
+		case "sl":
+			c.SelectedLine = value
+		case "cx":
+			c.ContextLine = value
+		case "fn":
+			c.FileName = value
+		case "ln":
+			c.LineNumber = value
+		case "bn":
+			c.ByteOffset = value
+		case "se":
+			c.Separator = value
+		case "rv":
+			c.Reverse = true
+		case "ne":
+			c.NoErase = true
+		}
+	}
+
+	return &c
+}
+
+// start возвращает последовательность, включающую цвет sgr
+func (c *Colors) start(sgr string) string {
+	if c.NoErase {
+		return "\x1b[" + sgr + "m"
+	}
+
+	return "\x1b[" + sgr + "m\x1b[K"
+}
+
+// end возвращает последовательность, сбрасывающую цвет
+func (c *Colors) end() string {
+	if c.NoErase {
+		return "\x1b[m"
+	}
+
+	return "\x1b[m\x1b[K"
+}
+
+// paint раскрашивает text цветом sgr. Если цвет не задан или раскраска выключена (c == nil), text не меняется.
+func (c *Colors) paint(sgr, text string) string {
+	if c == nil || sgr == "" {
+		return text
+	}
+
+	return c.start(sgr) + text + c.end()
+}
+
+// Методы ниже возвращают цвета отдельных частей вывода и допускают c == nil (раскраска выключена)
+
+func (c *Colors) fileName() string {
+	if c == nil {
+		return ""
+	}
+
+	return c.FileName
+}
+
+func (c *Colors) lineNumber() string {
+	if c == nil {
+		return ""
+	}
+
+	return c.LineNumber
+}
+
+func (c *Colors) byteOffset() string {
+	if c == nil {
+		return ""
+	}
+
+	return c.ByteOffset
+}
+
+func (c *Colors) separator() string {
+	if c == nil {
+		return ""
+	}
+
+	return c.Separator
+}
+
+// matchColor возвращает цвет найденного фрагмента в выбранной строке или в строке контекста
+func (c *Colors) matchColor(selected bool) string {
+	switch {
+	case c == nil:
+		return ""
+	case selected:
+		return c.SelectedMatch
+	default:
+		return c.ContextMatch
+	}
+}
+
+// lineColor возвращает цвет всей строки. При rv и -v цвета выбранных строк и строк контекста меняются местами.
+func (c *Colors) lineColor(selected, invert bool) string {
+	switch {
+	case c == nil:
+		return ""
+	case selected != (c.Reverse && invert):
+		return c.SelectedLine
+	default:
+		return c.ContextLine
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseColors(t *testing.T) {
+	colors := ParseColors(DefaultColors(), "mt=4:fn=34:sl=1:unknown=7:ne")
+
+	if colors.SelectedMatch != "4" || colors.ContextMatch != "4" {
+		t.Errorf("mt is not applied: %+v", colors)
+	}
+
+	if colors.FileName != "34" || colors.SelectedLine != "1" || colors.LineNumber != "32" || !colors.NoErase {
+		t.Errorf("unexpected colors: %+v", colors)
+	}
+
+	if actual := DefaultColors().paint("", "text"); actual != "text" {
+		t.Errorf("empty color must not change text: %q", actual)
+	}
+}
+
+func TestColorMode_Set(t *testing.T) {
+	var mode colorMode
+
+	for value, expected := range map[string]colorMode{"true": colorAuto, "always": colorAlways, "none": colorNever} {
+		if err := mode.Set(value); err != nil || mode != expected {
+			t.Errorf("Set(%q) = %q, %v (expected %q)", value, mode, err, expected)
+		}
+	}
+
+	if err := mode.Set("sometimes"); err == nil {
+		t.Errorf("expected error for invalid mode")
+	}
+}
+
+func TestPrinter_PrintColors(t *testing.T) {
+	const input = "a foo b foo\nbar\n"
+
+	tests := []struct {
+		printer  *Printer
+		invert   bool
+		expected string
+	}{
+		{
+			printer: &Printer{fileName: "c.txt", withFileName: true, lineNumbers: true, colors: DefaultColors()},
+			expected: "\x1b[35m\x1b[Kc.txt\x1b[m\x1b[K\x1b[36m\x1b[K:\x1b[m\x1b[K" +
+				"\x1b[32m\x1b[K1\x1b[m\x1b[K\x1b[36m\x1b[K:\x1b[m\x1b[K" +
+				"a \x1b[01;31m\x1b[Kfoo\x1b[m\x1b[K b \x1b[01;31m\x1b[Kfoo\x1b[m\x1b[K\n",
+		},
+		{
+			// С -v подсвечиваются совпадения в строках контекста, а выбранные строки выводятся без подсветки
+			printer:  &Printer{linesAfter: 1, invert: true, colors: ParseColors(DefaultColors(), "mc=5:sl=1")},
+			invert:   true,
+			expected: "\x1b[1m\x1b[Kbar\x1b[m\x1b[K\n",
+		},
+		{
+			printer:  &Printer{linesBefore: 1, invert: true, colors: ParseColors(DefaultColors(), "mc=5:sl=1:cx=2")},
+			invert:   true,
+			expected: "\x1b[2m\x1b[Ka \x1b[5m\x1b[Kfoo\x1b[m\x1b[K\x1b[2m\x1b[K b \x1b[5m\x1b[Kfoo\x1b[m\x1b[K\n\x1b[1m\x1b[Kbar\x1b[m\x1b[K\n",
+		},
+	}
+
+	for _, c := range tests {
+		buf := &bytes.Buffer{}
+		search := &Search{predicate: mustPredicate(t, "foo"), invert: c.invert}
+		_, _ = c.printer.Print(search, strings.NewReader(input), buf)
+
+		if buf.String() != c.expected {
+			t.Errorf("unexpected result: %q (expected %q)", buf.String(), c.expected)
+		}
+	}
+}
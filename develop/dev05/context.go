@@ -0,0 +1,144 @@
+package main
+
+// lineRing - кольцевой буфер, хранящий не более заданного количества последних строк
+type lineRing struct {
+	lines []sourceLine
+	start int
+	size  int
+}
+
+func newLineRing(capacity int) *lineRing {
+	return &lineRing{lines: make([]sourceLine, capacity)}
+}
+
+// Push добавляет строку в буфер, вытесняя самую старую, если буфер заполнен
+func (r *lineRing) Push(line sourceLine) {
+	if len(r.lines) == 0 {
+		return
+	}
+
+	if r.size < len(r.lines) {
+		r.lines[(r.start+r.size)%len(r.lines)] = line
+		r.size++
+		return
+	}
+
+	r.lines[r.start] = line
+	r.start = (r.start + 1) % len(r.lines)
+}
+
+// Drain возвращает все строки буфера от старых к новым и очищает его
+func (r *lineRing) Drain() []sourceLine {
+	result := make([]sourceLine, r.size)
+	for i := range result {
+		result[i] = r.lines[(r.start+i)%len(r.lines)]
+	}
+
+	r.start, r.size = 0, 0
+	return result
+}
+
+// windowState - состояние окна контекста
+type windowState int
+
+const (
+	// Контекст после выбранной строки не выводится: строки копятся в буфере как возможный контекст перед следующей
+	// выбранной строкой
+	windowIdle windowState = iota
+
+	// Выводятся строки контекста после выбранной строки
+	windowAfter
+
+	// Поиск остановлен (например, по -m), но ещё выводятся строки контекста после последней выбранной строки
+	windowDraining
+
+	// Поиск остановлен, и больше ни одна строка не будет выведена
+	windowDone
+)
+
+// contextWindow - конечный автомат, который по очереди получает строки и решает, какие из них выводить: выбранные
+// строки, до linesBefore строк перед ними и до linesAfter строк после них. Каждая строка выводится не больше одного
+// раза, даже если окна контекста соседних выбранных строк перекрываются.
+type contextWindow struct {
+	state windowState
+
+	// Строки, которые будут выведены перед следующей выбранной строкой
+	before *lineRing
+
+	// Количество строк контекста после выбранной строки
+	linesAfter int
+
+	// Сколько ещё строк контекста нужно вывести в состоянии windowAfter
+	afterLeft int
+
+	// Номер последней выведенной строки; 0, если ничего не выводилось
+	lastPrinted int
+}
+
+func newContextWindow(linesBefore, linesAfter int) *contextWindow {
+	return &contextWindow{
+		state:      windowIdle,
+		before:     newLineRing(linesBefore),
+		linesAfter: linesAfter,
+	}
+}
+
+// Next принимает очередную строку и возвращает строки, которые нужно вывести сейчас, по порядку. newGroup сообщает,
+// что эти строки не примыкают к ранее выведенным, то есть начинают новую группу.
+func (w *contextWindow) Next(line sourceLine) (lines []sourceLine, newGroup bool) {
+	switch {
+	case w.state == windowDone:
+		return nil, false
+
+	case w.state == windowDraining:
+		// После остановки строки выводятся только как контекст, даже если они соответствуют условиям
+		line.selected = false
+		lines = []sourceLine{line}
+
+		w.afterLeft--
+		if w.afterLeft <= 0 {
+			w.state = windowDone
+		}
+
+	case line.selected:
+		// Выбранная строка: выводим накопленный контекст перед ней и её саму, затем начинаем отсчёт контекста после
+		lines = append(w.before.Drain(), line)
+		w.state, w.afterLeft = windowAfter, w.linesAfter
+
+	case w.state == windowAfter && w.afterLeft > 0:
+		// Строка входит в контекст после предыдущей выбранной строки
+		lines = []sourceLine{line}
+		w.afterLeft--
+
+	default:
+		// Строка может понадобиться как контекст перед следующей выбранной строкой
+		w.state = windowIdle
+		w.before.Push(line)
+		return nil, false
+	}
+
+	newGroup = w.lastPrinted == 0 || lines[0].number > w.lastPrinted+1
+	w.lastPrinted = line.number
+	return lines, newGroup
+}
+
+// Stop останавливает поиск: выбранных строк больше не будет, но контекст после последней выбранной строки ещё
+// нужно вывести
+func (w *contextWindow) Stop() {
+	if w.state == windowAfter && w.afterLeft > 0 {
+		w.state = windowDraining
+		return
+	}
+
+	w.state = windowDone
+}
+
+// Stopped сообщает, что поиск остановлен и выбранных строк больше не будет
+func (w *contextWindow) Stopped() bool {
+	return w.state == windowDraining || w.state == windowDone
+}
+
+// Done сообщает, что больше ни одна строка не будет выведена и чтение можно прекратить
+func (w *contextWindow) Done() bool {
+	return w.state == windowDone
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineRing(t *testing.T) {
+	ring := newLineRing(3)
+	for i := 1; i <= 5; i++ {
+		ring.Push(sourceLine{number: i})
+	}
+
+	lines := ring.Drain()
+	numbers := make([]int, len(lines))
+	for i, l := range lines {
+		numbers[i] = l.number
+	}
+
+	if !reflect.DeepEqual(numbers, []int{3, 4, 5}) {
+		t.Errorf("unexpected ring contents: %v", numbers)
+	}
+
+	if len(ring.Drain()) != 0 {
+		t.Errorf("ring is not empty after drain")
+	}
+}
+
+func TestContextWindow_Next(t *testing.T) {
+	window := newContextWindow(1, 1)
+	selected := []bool{false, true, false, false, false, true, true, false, false}
+
+	var printed []int
+	var groups []int
+
+	for i, s := range selected {
+		lines, newGroup := window.Next(sourceLine{number: i + 1, selected: s})
+		if newGroup {
+			groups = append(groups, lines[0].number)
+		}
+
+		for _, l := range lines {
+			printed = append(printed, l.number)
+		}
+	}
+
+	if !reflect.DeepEqual(printed, []int{1, 2, 3, 5, 6, 7, 8}) {
+		t.Errorf("unexpected printed lines: %v", printed)
+	}
+
+	if !reflect.DeepEqual(groups, []int{1, 5}) {
+		t.Errorf("unexpected group starts: %v", groups)
+	}
+}
+
+func TestContextWindow_Stop(t *testing.T) {
+	window := newContextWindow(0, 1)
+
+	lines, _ := window.Next(sourceLine{number: 1, selected: true})
+	window.Stop()
+
+	if len(lines) != 1 || window.Done() || !window.Stopped() {
+		t.Fatalf("window must drain trailing context after stop")
+	}
+
+	// Строка после остановки выводится как контекст, даже если она выбрана
+	lines, _ = window.Next(sourceLine{number: 2, selected: true})
+	if len(lines) != 1 || lines[0].selected {
+		t.Errorf("unexpected lines after stop: %+v", lines)
+	}
+
+	if !window.Done() {
+		t.Errorf("window must be done after trailing context")
+	}
+
+	if lines, _ := window.Next(sourceLine{number: 3, selected: true}); lines != nil {
+		t.Errorf("unexpected lines after done: %+v", lines)
+	}
+}
+
+// Ожидаемый вывод получен с помощью GNU grep 3.x на тех же входных данных и с теми же флагами
+func TestPrinter_PrintContextGNU(t *testing.T) {
+	const input = "a\nfoo\nb\nc\nd\nfoo\ne\nfoo\nf\ng\nh\ni\nfoo\n"
+
+	tests := []struct {
+		name    string
+		pattern string
+		invert  bool
+		printer *Printer
+		// Флаг --no-group-separator
+		noSeparator bool
+		expected    string
+	}{
+		{
+			name:     "-A 1 foo",
+			pattern:  "foo",
+			printer:  &Printer{linesAfter: 1},
+			expected: "foo\nb\n--\nfoo\ne\nfoo\nf\n--\nfoo\n",
+		},
+		{
+			name:     "-B 1 foo",
+			pattern:  "foo",
+			printer:  &Printer{linesBefore: 1},
+			expected: "a\nfoo\n--\nd\nfoo\ne\nfoo\n--\ni\nfoo\n",
+		},
+		{
+			name:     "-C 1 foo",
+			pattern:  "foo",
+			printer:  &Printer{linesBefore: 1, linesAfter: 1},
+			expected: "a\nfoo\nb\n--\nd\nfoo\ne\nfoo\nf\n--\ni\nfoo\n",
+		},
+		{
+			name:     "-A 2 -B 3 foo",
+			pattern:  "foo",
+			printer:  &Printer{linesBefore: 3, linesAfter: 2},
+			expected: "a\nfoo\nb\nc\nd\nfoo\ne\nfoo\nf\ng\nh\ni\nfoo\n",
+		},
+		{
+			name:     "-A 0 foo",
+			pattern:  "foo",
+			printer:  &Printer{},
+			expected: "foo\n--\nfoo\n--\nfoo\n--\nfoo\n",
+		},
+		{
+			name:     "-n -C 1 -v foo",
+			pattern:  "foo",
+			invert:   true,
+			printer:  &Printer{linesBefore: 1, linesAfter: 1, lineNumbers: true},
+			expected: "1:a\n2-foo\n3:b\n4:c\n5:d\n6-foo\n7:e\n8-foo\n9:f\n10:g\n11:h\n12:i\n13-foo\n",
+		},
+		{
+			name:     "-v -A 1 -n '^[b-d]$'",
+			pattern:  "^[b-d]$",
+			invert:   true,
+			printer:  &Printer{linesAfter: 1, lineNumbers: true},
+			expected: "1:a\n2:foo\n3-b\n--\n6:foo\n7:e\n8:foo\n9:f\n10:g\n11:h\n12:i\n13:foo\n",
+		},
+		{
+			name:     "-A 1 -B 2 -n -v '^[a-e]$'",
+			pattern:  "^[a-e]$",
+			invert:   true,
+			printer:  &Printer{linesBefore: 2, linesAfter: 1, lineNumbers: true},
+			expected: "1-a\n2:foo\n3-b\n4-c\n5-d\n6:foo\n7-e\n8:foo\n9:f\n10:g\n11:h\n12:i\n13:foo\n",
+		},
+		{
+			name:     "-o -A 1 -n foo",
+			pattern:  "foo",
+			printer:  &Printer{linesAfter: 1, lineNumbers: true, onlyMatching: true},
+			expected: "2:foo\n--\n6:foo\n8:foo\n--\n13:foo\n",
+		},
+		{
+			name:     "-C 1 --group-separator=:: foo",
+			pattern:  "foo",
+			printer:  &Printer{linesBefore: 1, linesAfter: 1, groupSeparator: "::"},
+			expected: "a\nfoo\nb\n::\nd\nfoo\ne\nfoo\nf\n::\ni\nfoo\n",
+		},
+		{
+			name:        "-C 1 --no-group-separator foo",
+			pattern:     "foo",
+			printer:     &Printer{linesBefore: 1, linesAfter: 1},
+			noSeparator: true,
+			expected:    "a\nfoo\nb\nd\nfoo\ne\nfoo\nf\ni\nfoo\n",
+		},
+	}
+
+	for _, c := range tests {
+		printer := *c.printer
+		if printer.groupSeparator == "" {
+			printer.groupSeparator = "--"
+		}
+
+		printer.groupSeparators = !c.noSeparator
+
+		buf := &bytes.Buffer{}
+		search := &Search{predicate: mustPredicate(t, c.pattern), invert: c.invert}
+		if _, err := printer.Print(search, strings.NewReader(input), buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != c.expected {
+			t.Errorf("%s: unexpected result %q (expected %q)", c.name, buf.String(), c.expected)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+)
+
+// Магические числа поддерживаемых форматов сжатия
+var (
+	gzipMagic      = []byte{0x1f, 0x8b}
+	bzip2Magic     = []byte("BZh")
+	zstdMagicBytes = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Decompress определяет формат сжатия данных reader по магическому числу в начале и возвращает reader, выдающий
+// распакованные данные. Поддерживаются gzip, bzip2 и zstd; данные в другом формате возвращаются без изменений.
+func Decompress(reader io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(reader)
+
+	// Ошибку чтения вернёт первое чтение из результата, поэтому здесь её можно не проверять
+	magic, _ := buffered.Peek(len(zstdMagicBytes))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		// По умолчанию gzip.Reader читает и склеенные друг с другом потоки, как gzip -d
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(buffered), nil
+	case bytes.HasPrefix(magic, zstdMagicBytes):
+		return NewZstdReader(buffered), nil
+	default:
+		return buffered, nil
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Результат bzip2 для "alpha\nbeta error\ngamma\n"
+const bzip2Fixture = "" +
+	"425a6839314159265359a98c5aed000003d1800010400032c6d40020003100d0" +
+	"0113ca0794da86ac3536c751890770caf8bb9229c284854c62d768"
+
+func gzipped(t *testing.T, s string) []byte {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	text := "alpha\nbeta error\ngamma\n"
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{name: "plain", input: []byte(text), expected: text},
+		{name: "empty", input: nil, expected: ""},
+		{name: "short", input: []byte{0x1f}, expected: "\x1f"},
+		{name: "gzip", input: gzipped(t, text), expected: text},
+		{name: "gzip multistream", input: append(gzipped(t, "one\n"), gzipped(t, "two\n")...), expected: "one\ntwo\n"},
+		{name: "bzip2", input: mustHex(t, bzip2Fixture), expected: text},
+		{name: "zstd", input: mustHex(t, zstdFixture), expected: zstdFixtureText},
+	}
+
+	for _, c := range tests {
+		reader, err := Decompress(bytes.NewReader(c.input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+
+		actual, err := io.ReadAll(reader)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+		} else if string(actual) != c.expected {
+			t.Errorf("%s: unexpected result: %q (expected %q)", c.name, actual, c.expected)
+		}
+	}
+}
+
+func TestPrinter_PrintDecompress(t *testing.T) {
+	input := mustHex(t, zstdFixture)
+
+	// Без -z сжатые данные ищутся как есть
+	printers := map[bool]string{
+		true:  "6:error warning info retry error 308\n",
+		false: "",
+	}
+
+	for decompress, expected := range printers {
+		printer := &Printer{lineNumbers: true, decompress: decompress}
+		buf := &strings.Builder{}
+
+		_, err := printer.Print(&Search{predicate: mustPredicate(t, "^error warning")}, bytes.NewReader(input), buf)
+		if err != nil {
+			t.Errorf("decompress=%v: unexpected error: %v", decompress, err)
+		}
+
+		if actual := buf.String(); actual != expected {
+			t.Errorf("decompress=%v: unexpected output: %q (expected %q)", decompress, actual, expected)
+		}
+	}
+
+	// Обрезанный заголовок gzip - ошибка чтения файла
+	printer := &Printer{decompress: true}
+	truncated := gzipped(t, "error\n")[:5]
+	if _, err := printer.Print(&Search{predicate: mustPredicate(t, "error")}, bytes.NewReader(truncated), io.Discard); err == nil {
+		t.Error("expected error for truncated gzip input")
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Булевы выражения над шаблонами (--expr), например: error && !timeout && (db || cache)
+//
+// Грамматика:
+//
+//	expr    = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | primary
+//	primary = "(" expr ")" | pattern
+//
+// Шаблон - слово без пробелов и символов ( ) ! & | " ', либо строка в кавычках. В двойных кавычках \" и \\
+// означают кавычку и обратную косую черту, остальные обратные косые черты сохраняются для регулярного выражения.
+// В одинарных кавычках текст берётся как есть. Каждый шаблон компилируется с общими параметрами (-F, -i, -w, -x, -P).
+
+// exprToken - лексема выражения
+type exprToken struct {
+	// "(", ")", "!", "&&", "||" или пустая строка для шаблона
+	operator string
+
+	// Текст шаблона
+	pattern string
+
+	// Смещение лексемы в выражении, для сообщений об ошибках
+	offset int
+}
+
+// tokenizeExpression разбивает выражение на лексемы
+func tokenizeExpression(expression string) ([]exprToken, error) {
+	var tokens []exprToken
+
+	for i := 0; i < len(expression); {
+		c := expression[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '!':
+			tokens = append(tokens, exprToken{operator: string(c), offset: i})
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(expression) || expression[i+1] != c {
+				return nil, fmt.Errorf("unexpected %q at offset %d: use %c%c or quote the pattern", c, i, c, c)
+			}
+
+			tokens = append(tokens, exprToken{operator: expression[i : i+2], offset: i})
+			i += 2
+		case c == '"' || c == '\'':
+			pattern, n, err := unquotePattern(expression[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at offset %d", err, i)
+			}
+
+			tokens = append(tokens, exprToken{pattern: pattern, offset: i})
+			i += n
+		default:
+			end := strings.IndexFunc(expression[i:], func(r rune) bool {
+				return unicode.IsSpace(r) || strings.ContainsRune(`()!&|"'`, r)
+			})
+
+			if end < 0 {
+				end = len(expression) - i
+			}
+
+			tokens = append(tokens, exprToken{pattern: expression[i : i+end], offset: i})
+			i += end
+		}
+	}
+
+	return tokens, nil
+}
+
+// unquotePattern читает шаблон в кавычках в начале s и возвращает его текст и длину вместе с кавычками
+func unquotePattern(s string) (string, int, error) {
+	quote := s[0]
+	pattern := &strings.Builder{}
+
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == quote:
+			return pattern.String(), i + 1, nil
+		case c == '\\' && quote == '"' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\'):
+			pattern.WriteByte(s[i+1])
+			i++
+		default:
+			pattern.WriteByte(c)
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated quoted pattern")
+}
+
+// exprParser - разбор выражения рекурсивным спуском, сразу собирающий функцию проверки строк
+type exprParser struct {
+	tokens   []exprToken
+	position int
+	options  PatternOptions
+
+	// Длина выражения: смещение для ошибок в конце выражения
+	end int
+}
+
+// CompileExpression компилирует булево выражение над шаблонами в одну функцию проверки строк. Найденными фрагментами
+// строки считаются фрагменты всех подошедших шаблонов, кроме стоящих под отрицанием.
+func CompileExpression(expression string, options PatternOptions) (LinePredicate, error) {
+	tokens, err := tokenizeExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	p := &exprParser{tokens: tokens, options: options, end: len(expression)}
+
+	predicate, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected %s at offset %d", t, t.offset)
+	}
+
+	return predicate, nil
+}
+
+// String описывает лексему для сообщений об ошибках
+func (t exprToken) String() string {
+	if t.operator != "" {
+		return fmt.Sprintf("%q", t.operator)
+	}
+
+	return fmt.Sprintf("pattern %q", t.pattern)
+}
+
+func (p *exprParser) peek() (exprToken, bool) {
+	if p.position >= len(p.tokens) {
+		return exprToken{}, false
+	}
+
+	return p.tokens[p.position], true
+}
+
+// accept пропускает следующую лексему, если это оператор operator
+func (p *exprParser) accept(operator string) bool {
+	if t, ok := p.peek(); ok && t.operator == operator {
+		p.position++
+		return true
+	}
+
+	return false
+}
+
+func (p *exprParser) parseOr() (LinePredicate, error) {
+	operands, err := p.parseOperands("||", p.parseAnd)
+	if err != nil || len(operands) == 1 {
+		return firstOperand(operands), err
+	}
+
+	return func(s string) []Span {
+		// Проверяются все варианты, чтобы подсветить фрагменты каждого подошедшего шаблона
+		var result []Span
+		for _, operand := range operands {
+			if spans := operand(s); spans != nil {
+				result = append(result, spans...)
+				if result == nil {
+					result = []Span{}
+				}
+			}
+		}
+
+		return mergeSpans(result)
+	}, nil
+}
+
+func (p *exprParser) parseAnd() (LinePredicate, error) {
+	operands, err := p.parseOperands("&&", p.parseUnary)
+	if err != nil || len(operands) == 1 {
+		return firstOperand(operands), err
+	}
+
+	return func(s string) []Span {
+		result := []Span{}
+		for _, operand := range operands {
+			spans := operand(s)
+			if spans == nil {
+				return nil
+			}
+
+			result = append(result, spans...)
+		}
+
+		return mergeSpans(result)
+	}, nil
+}
+
+// parseOperands разбирает последовательность операндов, разделённых оператором operator
+func (p *exprParser) parseOperands(operator string, parse func() (LinePredicate, error)) ([]LinePredicate, error) {
+	var operands []LinePredicate
+
+	for {
+		operand, err := parse()
+		if err != nil {
+			return nil, err
+		}
+
+		operands = append(operands, operand)
+
+		if !p.accept(operator) {
+			return operands, nil
+		}
+	}
+}
+
+func firstOperand(operands []LinePredicate) LinePredicate {
+	if len(operands) == 0 {
+		return nil
+	}
+
+	return operands[0]
+}
+
+func (p *exprParser) parseUnary() (LinePredicate, error) {
+	if !p.accept("!") {
+		return p.parsePrimary()
+	}
+
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	// Под отрицанием фрагментов нет: строка подходит, как раз если шаблон в ней не найден
+	return func(s string) []Span {
+		if operand(s) != nil {
+			return nil
+		}
+
+		return []Span{}
+	}, nil
+}
+
+func (p *exprParser) parsePrimary() (LinePredicate, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of expression at offset %d", p.end)
+	}
+
+	p.position++
+
+	switch t.operator {
+	case "":
+		predicate, err := CompilePatterns([]string{t.pattern}, p.options)
+		if err != nil {
+			return nil, fmt.Errorf("%s at offset %d: %w", t, t.offset, err)
+		}
+
+		return predicate, nil
+	case "(":
+		predicate, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ) for ( at offset %d", t.offset)
+		}
+
+		return predicate, nil
+	default:
+		return nil, fmt.Errorf("unexpected %s at offset %d", t, t.offset)
+	}
+}
+
+// mergeSpans упорядочивает фрагменты по началу и объединяет пересекающиеся, чтобы их можно было подсветить и вывести
+// с -o так же, как фрагменты одного шаблона
+func mergeSpans(spans []Span) []Span {
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].Start != spans[j].Start {
+			return spans[i].Start < spans[j].Start
+		}
+
+		return spans[i].End > spans[j].End
+	})
+
+	merged := spans[:0]
+	for _, span := range spans {
+		if last := len(merged) - 1; last >= 0 && span.Start < merged[last].End {
+			merged[last].End = max(merged[last].End, span.End)
+			continue
+		}
+
+		merged = append(merged, span)
+	}
+
+	return merged
+}
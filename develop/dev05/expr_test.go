@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileExpression(t *testing.T) {
+	lines := []string{
+		"error db down",
+		"error timeout db",
+		"error cache miss",
+		"info db ok",
+		"a|b (x)",
+	}
+
+	tests := []struct {
+		expression string
+		options    PatternOptions
+		expected   []bool
+	}{
+		{expression: "error", expected: []bool{true, true, true, false, false}},
+		{expression: "error && !timeout && (db || cache)", expected: []bool{true, false, true, false, false}},
+		{expression: "!error", expected: []bool{false, false, false, true, true}},
+		{expression: "!!error", expected: []bool{true, true, true, false, false}},
+		{expression: "db || cache && info", expected: []bool{true, true, false, true, false}},
+		{expression: "(db || cache) && info", expected: []bool{false, false, false, true, false}},
+		{expression: `"a\|b"`, expected: []bool{false, false, false, false, true}},
+		{expression: `'a|b' && "(x)"`, options: PatternOptions{Fixed: true}, expected: []bool{false, false, false, false, true}},
+		{expression: `ERROR && !DB`, options: PatternOptions{IgnoreCase: true}, expected: []bool{false, false, true, false, false}},
+		{expression: `err`, options: PatternOptions{WholeWord: true}, expected: []bool{false, false, false, false, false}},
+	}
+
+	for _, c := range tests {
+		predicate, err := CompileExpression(c.expression, c.options)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.expression, err)
+			continue
+		}
+
+		actual := make([]bool, len(lines))
+		for i, line := range lines {
+			actual[i] = predicate(line) != nil
+		}
+
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s: unexpected result: %v (expected %v)", c.expression, actual, c.expected)
+		}
+	}
+}
+
+func TestCompileExpression_Spans(t *testing.T) {
+	tests := []struct {
+		expression string
+		line       string
+		expected   []Span
+	}{
+		// Фрагменты всех подошедших шаблонов упорядочиваются по началу
+		{expression: "db && error", line: "error db", expected: []Span{{0, 5}, {6, 8}}},
+		{expression: "missing || db || error", line: "error db", expected: []Span{{0, 5}, {6, 8}}},
+
+		// Пересекающиеся фрагменты объединяются
+		{expression: "erro && rror", line: "error", expected: []Span{{0, 5}}},
+
+		// Под отрицанием фрагментов нет, но строка подходит
+		{expression: "!timeout", line: "error", expected: []Span{}},
+		{expression: "error && !timeout", line: "error", expected: []Span{{0, 5}}},
+	}
+
+	for _, c := range tests {
+		predicate, err := CompileExpression(c.expression, PatternOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := predicate(c.line); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s: unexpected spans: %v (expected %v)", c.expression, actual, c.expected)
+		}
+	}
+}
+
+func TestCompileExpression_Errors(t *testing.T) {
+	tests := map[string]string{
+		"":              "empty expression",
+		"error && (db":  "missing ) for ( at offset 9",
+		"error & db":    "unexpected '&' at offset 6",
+		"error db":      `unexpected pattern "db" at offset 6`,
+		"error &&":      "unexpected end of expression at offset 8",
+		")":             `unexpected ")" at offset 0`,
+		`"unterminated`: "unterminated quoted pattern at offset 0",
+		`"a(" || b`:     `pattern "a(" at offset 0`,
+	}
+
+	for expression, expected := range tests {
+		_, err := CompileExpression(expression, PatternOptions{})
+		if err == nil {
+			t.Errorf("%q: expected error", expression)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: unexpected error: %v (expected %q)", expression, err, expected)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// stringList - значение флага, который можно передать несколько раз (например, --include)
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// listFlag регистрирует флаг, который можно передать несколько раз
+func listFlag(name, usage string) *stringList {
+	l := &stringList{}
+	flag.Var(l, name, usage)
+	return l
+}
+
+// FileFilter решает, какие файлы и каталоги нужно просматривать. Шаблоны сравниваются с базовым именем файла.
+type FileFilter struct {
+	// Если список не пуст, просматриваются только файлы, подходящие хотя бы под один шаблон
+	Include []string
+
+	// Файлы, подходящие под один из шаблонов, пропускаются
+	Exclude []string
+
+	// Каталоги, подходящие под один из шаблонов, пропускаются при рекурсивном обходе
+	ExcludeDir []string
+}
+
+// matchAny проверяет, подходит ли имя хотя бы под один из шаблонов
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchFile проверяет, нужно ли просматривать файл с путём path
+func (f *FileFilter) MatchFile(path string) bool {
+	name := filepath.Base(path)
+
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+
+	return !matchAny(f.Exclude, name)
+}
+
+// MatchDir проверяет, нужно ли заходить в каталог с путём path
+func (f *FileFilter) MatchDir(path string) bool {
+	return !matchAny(f.ExcludeDir, filepath.Base(path))
+}
+
+// WalkInputs перебирает файлы из списка paths и вызывает visit для каждого файла, который нужно просмотреть. Если
+// recursive, каталоги обходятся рекурсивно, иначе для них сообщается об ошибке; пустой список при recursive означает
+// текущий каталог, и тогда пути выводятся без префикса "./". Символические ссылки внутри каталогов пропускаются, как и
+// в grep -r. Ошибки доступа передаются в report, и обход продолжается. Как только stopped возвращает true, обход
+// прекращается: оставшиеся пути не проверяются и ошибки о них не сообщаются.
+func WalkInputs(
+	paths []string,
+	recursive bool,
+	filter *FileFilter,
+	stopped func() bool,
+	visit func(path string),
+	report func(error),
+) {
+	if len(paths) == 0 && recursive {
+		walkDir(".", "", filter, stopped, visit, report)
+		return
+	}
+
+	for _, root := range paths {
+		if stopped() {
+			return
+		}
+
+		// "-" обозначает стандартный ввод
+		if root == "-" {
+			visit(root)
+			continue
+		}
+
+		info, err := os.Stat(root)
+		if err != nil {
+			report(err)
+			continue
+		}
+
+		if !info.IsDir() {
+			if filter.MatchFile(root) {
+				visit(root)
+			}
+
+			continue
+		}
+
+		if !recursive {
+			report(&fs.PathError{Op: "read", Path: root, Err: errIsDirectory})
+			continue
+		}
+
+		prefix := root
+		if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+			prefix += string(filepath.Separator)
+		}
+
+		walkDir(root, prefix, filter, stopped, visit, report)
+	}
+}
+
+// walkDir рекурсивно обходит каталог root. Пути внутри него передаются в visit относительно root с префиксом prefix:
+// так, как это делает grep, root "." даёт пути вида "./a.txt".
+func walkDir(root, prefix string, filter *FileFilter, stopped func() bool, visit func(path string), report func(error)) {
+	_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if stopped() {
+			return fs.SkipAll
+		}
+
+		if err != nil {
+			report(err)
+			return nil
+		}
+
+		if path == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			report(err)
+			return nil
+		}
+
+		path = prefix + rel
+
+		switch {
+		case entry.IsDir():
+			if !filter.MatchDir(path) {
+				return filepath.SkipDir
+			}
+		case entry.Type()&fs.ModeSymlink != 0:
+			return nil
+		case filter.MatchFile(path):
+			visit(path)
+		}
+
+		return nil
+	})
+}
+
+// SearchFiles ищет подходящие строки во всех файлах, найденных WalkInputs, с помощью workers параллельных
+// обработчиков. Для каждого файла prepare создаёт Search и Printer, а после поиска в файле вызывается finish; если
+// finish возвращает true, оставшиеся файлы не просматриваются. Вывод каждого файла записывается в writer целиком
+// и в порядке обнаружения файлов. Ошибки открытия и чтения файлов передаются в report и не прерывают поиск;
+// возвращается только ошибка записи в writer. Вызовы finish и report не выполняются одновременно. После того как
+// finish попросил прекратить поиск, ошибки больше не сообщаются: grep до этих файлов уже не дошёл бы.
+func SearchFiles(
+	paths []string,
+	recursive bool,
+	filter *FileFilter,
+	workers int,
+	writer io.Writer,
+	prepare func(path string) (*Search, *Printer),
+	finish func(search *Search) (stop bool),
+	report func(error),
+) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Задание - файл для поиска или ошибка обхода. Ошибки обхода идут через ту же очередь и тоже получают место в
+	// выводе, поэтому сообщаются в порядке обхода и только после того, как предыдущие файлы просмотрены и не попросили
+	// прекратить поиск.
+	type job struct {
+		path string
+		slot *OutputSlot
+		err  error
+	}
+
+	// Каждое выделенное, но не записанное место занято одним из обработчиков, поэтому больше workers мест не нужно
+	output := NewOrderedOutput(writer, workers)
+	jobs := make(chan job)
+
+	// Устанавливается, когда finish просит прекратить поиск
+	stopped := &atomic.Bool{}
+
+	lock := &sync.Mutex{}
+	reportSafe := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if !stopped.Load() {
+			report(err)
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := range jobs {
+				if j.err != nil {
+					j.slot.WaitTurn()
+					reportSafe(j.err)
+					_ = j.slot.Close()
+					continue
+				}
+
+				if !stopped.Load() {
+					search, printer := prepare(j.path)
+
+					if err := searchFile(j.path, search, printer, j.slot); err != nil {
+						reportSafe(err)
+					}
+
+					lock.Lock()
+					if finish(search) {
+						stopped.Store(true)
+					}
+					lock.Unlock()
+				}
+
+				_ = j.slot.Close()
+			}
+		}()
+	}
+
+	// Место для вывода выделяется в момент обнаружения файла, поэтому порядок вывода совпадает с порядком обхода
+	WalkInputs(paths, recursive, filter, stopped.Load, func(path string) {
+		jobs <- job{path: path, slot: output.Slot()}
+	}, func(err error) {
+		jobs <- job{err: err, slot: output.Slot()}
+	})
+
+	close(jobs)
+	wg.Wait()
+
+	return output.Err()
+}
+
+// searchFile ищет подходящие строки в одном файле. Ошибки записи не возвращаются: их запоминает OrderedOutput.
+func searchFile(path string, search *Search, printer *Printer, slot *OutputSlot) error {
+	input, err := OpenInput(path)
+	if err != nil {
+		return err
+	}
+
+	defer input.Close()
+
+	_, err = printer.Print(search, input, slot)
+	if err != nil && slot.output.Err() == nil {
+		return fmt.Errorf("%s: %w", printer.fileName, err)
+	}
+
+	return nil
+}
+
+// errIsDirectory сообщает, что каталог передан без флага -r
+var errIsDirectory = isDirectoryError{}
+
+type isDirectoryError struct{}
+
+func (isDirectoryError) Error() string {
+	return "is a directory"
+}
+
+// OrderedOutput собирает вывод нескольких параллельно обрабатываемых файлов так, чтобы вывод каждого файла шёл одним
+// куском и в том порядке, в котором файлы были найдены. Вывод первого незавершённого файла сразу передаётся в writer,
+// вывод остальных накапливается в памяти до тех пор, пока не придёт их очередь.
+//
+// Чтобы медленный или большой первый файл (например, stdin) не заставлял держать в памяти вывод всех остальных,
+// одновременно существует не больше limit незавершённых мест: Slot ждёт, пока очередь продвинется. Кроме того,
+// место, до которого ещё не дошла очередь, накапливает не больше bufferLimit байт, после чего Write ждёт своей очереди.
+type OrderedOutput struct {
+	writer io.Writer
+	lock   *sync.Mutex
+	cond   *sync.Cond
+	err    error
+
+	// Места, вывод которых ещё не записан в writer целиком. Первое из них - текущее, его порядковый номер - head.
+	slots []*OutputSlot
+	head  int
+
+	limit       int
+	bufferLimit int
+
+	// Было ли что-нибудь записано в writer
+	written bool
+}
+
+// defaultSlotBufferLimit - сколько байт вывода может накопить файл, до которого ещё не дошла очередь
+const defaultSlotBufferLimit = 1 << 20
+
+// NewOrderedOutput создаёт OrderedOutput, в котором одновременно может быть не больше limit незавершённых мест.
+// Если limit меньше 1, количество мест не ограничивается.
+func NewOrderedOutput(writer io.Writer, limit int) *OrderedOutput {
+	lock := &sync.Mutex{}
+	return &OrderedOutput{
+		writer:      writer,
+		lock:        lock,
+		cond:        sync.NewCond(lock),
+		limit:       limit,
+		bufferLimit: defaultSlotBufferLimit,
+	}
+}
+
+// Slot создаёт место для вывода очередного файла. Если незавершённых мест уже limit, ждёт, пока текущее место не
+// будет закрыто.
+func (o *OrderedOutput) Slot() *OutputSlot {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for o.limit > 0 && len(o.slots) >= o.limit {
+		o.cond.Wait()
+	}
+
+	slot := &OutputSlot{output: o, index: o.head + len(o.slots)}
+	o.slots = append(o.slots, slot)
+	return slot
+}
+
+// Err возвращает первую ошибку записи в writer
+func (o *OrderedOutput) Err() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.err
+}
+
+// write записывает данные в writer, запоминая первую ошибку
+func (o *OrderedOutput) write(p []byte) {
+	if o.err != nil || len(p) == 0 {
+		return
+	}
+
+	o.written = true
+	_, o.err = o.writer.Write(p)
+}
+
+// separatorWriter - writer, который сам решает, нужен ли разделитель групп перед выводом файла
+type separatorWriter interface {
+	// WriteSeparator записывает разделитель, только если до этого уже что-то было выведено
+	WriteSeparator(p []byte) (int, error)
+}
+
+// OutputSlot - место в OrderedOutput для вывода одного файла. Реализует io.Writer.
+type OutputSlot struct {
+	output *OrderedOutput
+	index  int
+	buffer bytes.Buffer
+	closed bool
+
+	// Разделитель, который нужно вывести перед выводом файла, если до него что-то было выведено
+	separator []byte
+}
+
+func (s *OutputSlot) Write(p []byte) (int, error) {
+	o := s.output
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	// Если вывода накоплено слишком много, ждём, пока до файла дойдёт очередь
+	for o.head != s.index && s.buffer.Len() >= o.bufferLimit {
+		o.cond.Wait()
+	}
+
+	// Если до этого файла дошла очередь, пишем сразу в writer, иначе копим вывод
+	if o.head == s.index {
+		o.write(p)
+		return len(p), o.err
+	}
+
+	return s.buffer.Write(p)
+}
+
+// WriteSeparator записывает разделитель групп перед выводом файла. Разделитель нужен, только если для предыдущих
+// файлов что-то было выведено, поэтому, пока до файла не дошла очередь, решение откладывается.
+func (s *OutputSlot) WriteSeparator(p []byte) (int, error) {
+	o := s.output
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.head != s.index {
+		s.separator = append([]byte(nil), p...)
+		return len(p), nil
+	}
+
+	if o.written {
+		o.write(p)
+	}
+
+	return len(p), o.err
+}
+
+// WaitTurn ждёт, пока до места не дойдёт очередь, то есть пока вывод всех предыдущих мест не будет записан
+func (s *OutputSlot) WaitTurn() {
+	o := s.output
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for o.head != s.index {
+		o.cond.Wait()
+	}
+}
+
+// Close сообщает, что вывод файла завершён. Если это был текущий файл, очередь переходит к следующим файлам, и их
+// накопленный вывод записывается в writer. Записанные места удаляются из OrderedOutput.
+func (s *OutputSlot) Close() error {
+	o := s.output
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	s.closed = true
+
+	for len(o.slots) > 0 && o.slots[0].closed {
+		o.slots[0] = nil
+		o.slots = o.slots[1:]
+		o.head++
+
+		// Следующий файл становится текущим: записываем всё, что он успел вывести
+		if len(o.slots) > 0 {
+			next := o.slots[0]
+			if next.separator != nil && o.written {
+				o.write(next.separator)
+			}
+
+			o.write(next.buffer.Bytes())
+			next.buffer = bytes.Buffer{}
+		}
+	}
+
+	// Очередь могла продвинуться: будим ожидающих в Slot и Write
+	o.cond.Broadcast()
+
+	return o.err
+}
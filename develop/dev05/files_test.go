@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileFilter(t *testing.T) {
+	filter := &FileFilter{
+		Include:    []string{"*.go", "*.txt"},
+		Exclude:    []string{"*_test.go"},
+		ExcludeDir: []string{".git", "vendor"},
+	}
+
+	files := map[string]bool{
+		"main.go":          true,
+		"dir/notes.txt":    true,
+		"dir/main_test.go": false,
+		"readme.md":        false,
+	}
+
+	for path, expected := range files {
+		if actual := filter.MatchFile(path); actual != expected {
+			t.Errorf("MatchFile(%q) = %v (expected %v)", path, actual, expected)
+		}
+	}
+
+	dirs := map[string]bool{
+		"src":        true,
+		"src/vendor": false,
+		".git":       false,
+	}
+
+	for path, expected := range dirs {
+		if actual := filter.MatchDir(path); actual != expected {
+			t.Errorf("MatchDir(%q) = %v (expected %v)", path, actual, expected)
+		}
+	}
+}
+
+func TestOrderedOutput(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	output := NewOrderedOutput(buffer, 0)
+
+	first, second, third := output.Slot(), output.Slot(), output.Slot()
+
+	// Файлы завершаются в обратном порядке, но вывод должен идти в порядке выделения мест
+	_, _ = third.Write([]byte("3a\n"))
+	_, _ = second.Write([]byte("2a\n"))
+	_ = third.Close()
+	_, _ = first.Write([]byte("1a\n"))
+	_, _ = second.Write([]byte("2b\n"))
+
+	if actual := buffer.String(); actual != "1a\n" {
+		t.Errorf("unexpected output before close: %q", actual)
+	}
+
+	_ = first.Close()
+	_, _ = second.Write([]byte("2c\n"))
+	_ = second.Close()
+
+	if actual, expected := buffer.String(), "1a\n2a\n2b\n2c\n3a\n"; actual != expected {
+		t.Errorf("unexpected output: %q (expected %q)", actual, expected)
+	}
+}
+
+func TestOrderedOutput_WriteSeparator(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	output := NewOrderedOutput(buffer, 0)
+
+	empty, first, second := output.Slot(), output.Slot(), output.Slot()
+
+	// Перед первым выводом разделитель не нужен, перед последующими - нужен, даже если решение откладывается
+	_, _ = second.WriteSeparator([]byte("--\n"))
+	_, _ = second.Write([]byte("b\n"))
+	_ = empty.Close()
+	_, _ = first.WriteSeparator([]byte("--\n"))
+	_, _ = first.Write([]byte("a\n"))
+	_ = first.Close()
+	_ = second.Close()
+
+	if actual, expected := buffer.String(), "a\n--\nb\n"; actual != expected {
+		t.Errorf("unexpected output: %q (expected %q)", actual, expected)
+	}
+}
+
+func TestOrderedOutput_Limit(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	output := NewOrderedOutput(buffer, 2)
+	output.bufferLimit = 4
+
+	first, second := output.Slot(), output.Slot()
+
+	// Третье место выделяется только после того, как записан вывод первого файла
+	slots := make(chan *OutputSlot)
+	go func() {
+		slots <- output.Slot()
+	}()
+
+	// Второй файл упирается в ограничение буфера и ждёт своей очереди
+	written := make(chan struct{})
+	go func() {
+		_, _ = second.Write([]byte("2a\n2b\n"))
+		_, _ = second.Write([]byte("2c\n"))
+		close(written)
+	}()
+
+	select {
+	case <-slots:
+		t.Fatal("slot allocated beyond limit")
+	case <-written:
+		t.Fatal("write was not blocked by buffer limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, _ = first.Write([]byte("1a\n"))
+	_ = first.Close()
+
+	third := <-slots
+	<-written
+
+	if len(output.slots) != 2 || third.index != 2 {
+		t.Errorf("flushed slots were not trimmed: %d slots, index %d", len(output.slots), third.index)
+	}
+
+	_ = second.Close()
+	_, _ = third.Write([]byte("3a\n"))
+	_ = third.Close()
+
+	if actual, expected := buffer.String(), "1a\n2a\n2b\n2c\n3a\n"; actual != expected {
+		t.Errorf("unexpected output: %q (expected %q)", actual, expected)
+	}
+
+	if len(output.slots) != 0 {
+		t.Errorf("unexpected slots after close: %d", len(output.slots))
+	}
+}
+
+func TestSearchFiles(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		"a.txt":          "foo\nbar\n",
+		"b.log":          "foo\n",
+		"sub/c.txt":      "bar\nfoo\nfoo\n",
+		"sub/d.txt":      "bar\n",
+		"skip/e.txt":     "foo\n",
+		"sub/deep/f.txt": "foo\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := &FileFilter{Include: []string{"*.txt"}, ExcludeDir: []string{"skip"}}
+	isFoo := predicateOf(func(s string) bool {
+		return s == "foo"
+	})
+
+	tests := []struct {
+		printer  *Printer
+		expected string
+	}{
+		{
+			printer: &Printer{withFileName: true},
+			expected: "a.txt:foo\n" +
+				"sub/c.txt:foo\n" +
+				"sub/c.txt:foo\n" +
+				"sub/deep/f.txt:foo\n",
+		},
+		{
+			printer: &Printer{withFileName: true, onlyCount: true},
+			expected: "a.txt:1\n" +
+				"sub/c.txt:2\n" +
+				"sub/d.txt:0\n" +
+				"sub/deep/f.txt:1\n",
+		},
+		{
+			printer:  &Printer{listMatching: true},
+			expected: "a.txt\nsub/c.txt\nsub/deep/f.txt\n",
+		},
+		{
+			printer:  &Printer{listNonMatching: true},
+			expected: "sub/d.txt\n",
+		},
+	}
+
+	for _, c := range tests {
+		// Многократно повторяем поиск, чтобы убедиться, что порядок вывода не зависит от планировщика
+		for i := 0; i < 20; i++ {
+			buffer := &bytes.Buffer{}
+			var errs []error
+
+			err := SearchFiles([]string{root}, true, filter, 4, buffer, func(path string) (*Search, *Printer) {
+				name := filepath.ToSlash(strings.TrimPrefix(path, root+string(filepath.Separator)))
+				return &Search{predicate: isFoo}, c.printer.ForFile(name)
+			}, func(*Search) bool {
+				return false
+			}, func(err error) {
+				errs = append(errs, err)
+			})
+
+			if err != nil || len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v, %v", err, errs)
+			}
+
+			if actual := buffer.String(); actual != c.expected {
+				t.Fatalf("unexpected output: %q (expected %q)", actual, c.expected)
+			}
+		}
+	}
+
+	// Каталог без -r и несуществующий файл дают ошибки, но не прерывают поиск
+	var errs []string
+	buffer := &bytes.Buffer{}
+
+	paths := []string{filepath.Join(root, "sub"), filepath.Join(root, "missing.txt"), filepath.Join(root, "a.txt")}
+	err := SearchFiles(paths, false, &FileFilter{}, 2, buffer, func(path string) (*Search, *Printer) {
+		return &Search{predicate: isFoo}, &Printer{}
+	}, func(*Search) bool {
+		return false
+	}, func(err error) {
+		errs = append(errs, err.Error())
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := buffer.String(); actual != "foo\n" {
+		t.Errorf("unexpected output: %q", actual)
+	}
+
+	if len(errs) != 2 || !strings.Contains(errs[0], "is a directory") {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestSearchFiles_Stop(t *testing.T) {
+	root := t.TempDir()
+
+	for _, name := range []string{"match.txt", "dir/a.txt", "dir/sub/b.txt"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte("foo\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	isFoo := predicateOf(func(s string) bool {
+		return s == "foo"
+	})
+
+	// Как grep -q: после первого совпадения несуществующий файл и каталог уже не просматриваются и ошибок нет
+	paths := []string{filepath.Join(root, "match.txt"), filepath.Join(root, "missing.txt"), filepath.Join(root, "dir")}
+
+	for _, workers := range []int{1, 4} {
+		var prepared []string
+		var errs []error
+		lock := &sync.Mutex{}
+
+		err := SearchFiles(paths, true, &FileFilter{}, workers, io.Discard, func(path string) (*Search, *Printer) {
+			lock.Lock()
+			prepared = append(prepared, path)
+			lock.Unlock()
+
+			return &Search{predicate: isFoo}, &Printer{quiet: true}
+		}, func(search *Search) bool {
+			return search.count > 0
+		}, func(err error) {
+			errs = append(errs, err)
+		})
+
+		if err != nil || len(errs) > 0 {
+			t.Errorf("unexpected errors with %d workers: %v, %v", workers, err, errs)
+		}
+
+		// С одним обработчиком обход останавливается сразу после первого файла
+		if workers == 1 && len(prepared) != 1 {
+			t.Errorf("files searched after stop: %v", prepared)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultFollowInterval - как часто проверять, не появились ли в файле новые данные
+const DefaultFollowInterval = 250 * time.Millisecond
+
+// FollowReader читает файл, который продолжает расти, как tail -f: дойдя до конца файла, не возвращает io.EOF, а ждёт
+// новых данных. Если файл обрезали, чтение начинается с начала; если файл заменили другим (ротация логов), новый файл
+// открывается по тому же пути и читается с начала. Остаток старого файла перед этим дочитывается.
+type FollowReader struct {
+	path     string
+	interval time.Duration
+
+	// Открытый сейчас файл, его описание и количество прочитанных из него байтов. Защищены lock, потому что Close
+	// может быть вызван во время чтения.
+	lock   *sync.Mutex
+	file   *os.File
+	info   os.FileInfo
+	offset int64
+	closed bool
+}
+
+func NewFollowReader(path string, interval time.Duration) (*FollowReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if info.IsDir() {
+		_ = file.Close()
+		return nil, &fs.PathError{Op: "read", Path: path, Err: errIsDirectory}
+	}
+
+	return &FollowReader{path: path, interval: interval, lock: &sync.Mutex{}, file: file, info: info}, nil
+}
+
+func (f *FollowReader) Read(p []byte) (int, error) {
+	for {
+		n, retry, err := f.poll(p)
+		if n > 0 || err != nil {
+			return n, err
+		}
+
+		if !retry {
+			time.Sleep(f.interval)
+		}
+	}
+}
+
+// poll читает новые данные, если они есть. Дойдя до конца файла, проверяет, не обрезан ли он и не заменён ли другим;
+// retry означает, что чтение начнётся с начала и ждать не нужно. После Close возвращает io.EOF.
+func (f *FollowReader) poll(p []byte) (n int, retry bool, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return 0, false, io.EOF
+	}
+
+	n, err = f.file.Read(p)
+	f.offset += int64(n)
+
+	if n > 0 {
+		return n, false, nil
+	}
+
+	if err != nil && err != io.EOF {
+		return 0, false, err
+	}
+
+	retry, err = f.reopen()
+	return 0, retry, err
+}
+
+// reopen вызывается под lock и проверяет, не обрезан ли файл и не заменён ли другим. Возвращает true, если чтение нужно начать с начала.
+func (f *FollowReader) reopen() (bool, error) {
+	info, err := os.Stat(f.path)
+
+	// Во время ротации файла по этому пути может ещё не быть: ждём, пока он появится
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if !os.SameFile(info, f.info) {
+		file, err := os.Open(f.path)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		if info, err = file.Stat(); err != nil {
+			_ = file.Close()
+			return false, err
+		}
+
+		_ = f.file.Close()
+		f.file, f.info, f.offset = file, info, 0
+		return true, nil
+	}
+
+	if info.Size() < f.offset {
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+
+		f.offset = 0
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Close прекращает слежение: следующее чтение вернёт io.EOF
+func (f *FollowReader) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return nil
+	}
+
+	f.closed = true
+	return f.file.Close()
+}
+
+// FollowFiles следит за файлами paths и выводит подходящие строки по мере их появления. В отличие от SearchFiles, вывод
+// разных файлов не упорядочивается, а перемежается построчно. Поиск в файле заканчивается, только если Print
+// прекращает чтение сам (например, с -m или -q); finish вызывается после этого и может остановить слежение за всеми
+// файлами. Стандартный ввод читается до конца, как обычно. Все обратные вызовы выполняются в вызывающей горутине.
+func FollowFiles(
+	paths []string,
+	interval time.Duration,
+	writer io.Writer,
+	prepare func(path string) (*Search, *Printer),
+	finish func(search *Search) (stop bool),
+	report func(error),
+) {
+	type result struct {
+		search *Search
+		err    error
+	}
+
+	writer = &syncWriter{writer: writer, lock: &sync.Mutex{}}
+	results := make(chan result)
+
+	for _, path := range paths {
+		search, printer := prepare(path)
+
+		go func(path string) {
+			err := followFile(path, interval, search, printer, writer)
+			results <- result{search: search, err: err}
+		}(path)
+	}
+
+	for range paths {
+		r := <-results
+		if r.err != nil {
+			report(r.err)
+		}
+
+		if finish(r.search) {
+			return
+		}
+	}
+}
+
+// followFile ищет подходящие строки в файле, следя за его ростом
+func followFile(path string, interval time.Duration, search *Search, printer *Printer, writer io.Writer) error {
+	var input io.ReadCloser
+	var err error
+
+	if path == "-" {
+		input, err = OpenInput(path)
+	} else {
+		input, err = NewFollowReader(path, interval)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer input.Close()
+
+	if _, err := printer.Print(search, input, writer); err != nil {
+		return fmt.Errorf("%s: %w", printer.fileName, err)
+	}
+
+	return nil
+}
+
+// syncWriter позволяет нескольким горутинам писать в один writer. Printer выводит каждую строку одной записью,
+// поэтому строки разных файлов не перемешиваются.
+type syncWriter struct {
+	writer io.Writer
+	lock   *sync.Mutex
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.writer.Write(p)
+}
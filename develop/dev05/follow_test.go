@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuilder - strings.Builder, в который можно писать из другой горутины
+type lockedBuilder struct {
+	lock    sync.Mutex
+	builder strings.Builder
+}
+
+func (b *lockedBuilder) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.builder.Write(p)
+}
+
+func (b *lockedBuilder) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.builder.String()
+}
+
+// waitForOutput ждёт, пока вывод станет равен expected
+func waitForOutput(t *testing.T, output *lockedBuilder, expected string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for output.String() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected output: %q (expected %q)", output.String(), expected)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func appendFile(t *testing.T, path, text string) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	if _, err := file.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollowReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "start error 1\nok\n")
+
+	reader, err := NewFollowReader(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := &lockedBuilder{}
+	printer := &Printer{lineNumbers: true, linesAfter: 1}
+	done := make(chan error)
+
+	go func() {
+		_, err := printer.Print(&Search{predicate: mustPredicate(t, "error")}, reader, output)
+		done <- err
+	}()
+
+	expected := "1:start error 1\n2-ok\n"
+	waitForOutput(t, output, expected)
+
+	// Строка выводится, только когда дописана целиком
+	appendFile(t, path, "partial err")
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, path, "or 2\nafter\n")
+
+	expected += "3:partial error 2\n4-after\n"
+	waitForOutput(t, output, expected)
+
+	// Файл обрезан: чтение начинается с начала
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, path, "error after truncate\n")
+
+	expected += "5:error after truncate\n"
+	waitForOutput(t, output, expected)
+
+	// Ротация: остаток старого файла дочитывается, затем открывается новый файл
+	appendFile(t, path, "old tail error\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	appendFile(t, path, "new file error\n")
+
+	expected += "6:old tail error\n7:new file error\n"
+	waitForOutput(t, output, expected)
+
+	// После Close чтение заканчивается, как в конце обычного файла
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Print did not return after Close")
+	}
+}
+
+func TestFollowFiles(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+	appendFile(t, first, "")
+	appendFile(t, second, "")
+
+	output := &lockedBuilder{}
+	done := make(chan int)
+
+	// С -m 1 слежение за файлом заканчивается после первой найденной строки
+	go func() {
+		finished := 0
+		FollowFiles([]string{first, second}, time.Millisecond, output, func(path string) (*Search, *Printer) {
+			return &Search{predicate: mustPredicate(t, "ready")}, &Printer{
+				fileName:     filepath.Base(path),
+				withFileName: true,
+				limitCount:   true,
+				maxCount:     1,
+			}
+		}, func(search *Search) bool {
+			finished++
+			return false
+		}, func(err error) {
+			t.Error(err)
+		})
+
+		done <- finished
+	}()
+
+	appendFile(t, second, "second ready\nsecond ready again\n")
+	waitForOutput(t, output, "second.log:second ready\n")
+
+	appendFile(t, first, "not yet\nfirst ready\n")
+	waitForOutput(t, output, "second.log:second ready\nfirst.log:first ready\n")
+
+	select {
+	case finished := <-done:
+		if finished != 2 {
+			t.Errorf("unexpected number of finished files: %d", finished)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("FollowFiles did not return")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// Вывод в формате JSON Lines, совместимом с ripgrep --json: для каждого файла с найденными строками выводятся записи
+// begin, match и context для каждой строки и end со статистикой, а в конце - summary с общей статистикой.
+
+// Stats - статистика поиска в одном или нескольких файлах
+type Stats struct {
+	// Время поиска
+	Elapsed jsonDuration `json:"elapsed"`
+
+	// Количество просмотренных файлов и файлов, в которых нашлись строки
+	Searches          int `json:"searches"`
+	SearchesWithMatch int `json:"searches_with_match"`
+
+	// Количество прочитанных и выведенных байтов
+	BytesSearched int64 `json:"bytes_searched"`
+	BytesPrinted  int64 `json:"bytes_printed"`
+
+	// Количество выбранных строк и найденных в них фрагментов
+	MatchedLines int `json:"matched_lines"`
+	Matches      int `json:"matches"`
+}
+
+// Add прибавляет статистику other
+func (s *Stats) Add(other Stats) {
+	s.Elapsed += other.Elapsed
+	s.Searches += other.Searches
+	s.SearchesWithMatch += other.SearchesWithMatch
+	s.BytesSearched += other.BytesSearched
+	s.BytesPrinted += other.BytesPrinted
+	s.MatchedLines += other.MatchedLines
+	s.Matches += other.Matches
+}
+
+// jsonDuration выводит длительность так же, как ripgrep: секунды, наносекунды и строка для человека
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	duration := time.Duration(d)
+
+	return json.Marshal(struct {
+		Secs  int64  `json:"secs"`
+		Nanos int64  `json:"nanos"`
+		Human string `json:"human"`
+	}{
+		Secs:  int64(duration / time.Second),
+		Nanos: int64(duration % time.Second),
+		Human: fmt.Sprintf("%.6fs", duration.Seconds()),
+	})
+}
+
+// jsonText выводит текст как {"text": ...}, а если он не является корректным UTF-8 - как {"bytes": ...} в base64
+type jsonText string
+
+func (t jsonText) MarshalJSON() ([]byte, error) {
+	if utf8.ValidString(string(t)) {
+		return marshalJSON(struct {
+			Text string `json:"text"`
+		}{string(t)})
+	}
+
+	return marshalJSON(struct {
+		Bytes []byte `json:"bytes"`
+	}{[]byte(t)})
+}
+
+type jsonRecord struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+type jsonSubmatch struct {
+	Match jsonText `json:"match"`
+	Start int      `json:"start"`
+	End   int      `json:"end"`
+}
+
+type jsonLine struct {
+	Path           jsonText       `json:"path"`
+	Lines          jsonText       `json:"lines"`
+	LineNumber     int            `json:"line_number"`
+	AbsoluteOffset int64          `json:"absolute_offset"`
+	Submatches     []jsonSubmatch `json:"submatches"`
+}
+
+// marshalJSON кодирует value в JSON, не экранируя символы <, > и &, чтобы текст строк читался как есть
+func marshalJSON(value any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// writeJSON выводит запись на отдельной строке
+func writeJSON(writer io.Writer, recordType string, data any) (int, error) {
+	record, err := marshalJSON(jsonRecord{Type: recordType, Data: data})
+	if err != nil {
+		return 0, err
+	}
+
+	return writer.Write(append(record, '\n'))
+}
+
+// printJSONBegin выводит запись о начале вывода результатов файла
+func (p *Printer) printJSONBegin(writer io.Writer) (int, error) {
+	return writeJSON(writer, "begin", struct {
+		Path jsonText `json:"path"`
+	}{jsonText(p.fileName)})
+}
+
+// printJSONLine выводит выбранную строку как запись match, а строку контекста - как запись context. Найденные
+// фрагменты указываются для строк, которые соответствуют шаблону, как и при подсветке.
+func (p *Printer) printJSONLine(writer io.Writer, line sourceLine) (int, error) {
+	submatches := []jsonSubmatch{}
+	if line.selected != p.invert {
+		for _, span := range line.spans {
+			if span.Start == span.End {
+				continue
+			}
+
+			submatches = append(submatches, jsonSubmatch{
+				Match: jsonText(line.text[span.Start:span.End]),
+				Start: span.Start,
+				End:   span.End,
+			})
+		}
+	}
+
+	recordType := "context"
+	if line.selected {
+		recordType = "match"
+	}
+
+	return writeJSON(writer, recordType, jsonLine{
+		Path:           jsonText(p.fileName),
+		Lines:          jsonText(line.text + line.eol),
+		LineNumber:     line.number,
+		AbsoluteOffset: line.offset,
+		Submatches:     submatches,
+	})
+}
+
+// printJSONEnd выводит запись о конце вывода результатов файла со статистикой поиска в нём. Для двоичного файла
+// binaryOffset - смещение первого байта NUL, для текстового - nil.
+func (p *Printer) printJSONEnd(writer io.Writer, stats Stats, binaryOffset *int64) (int, error) {
+	return writeJSON(writer, "end", struct {
+		Path         jsonText `json:"path"`
+		BinaryOffset *int64   `json:"binary_offset"`
+		Stats        Stats    `json:"stats"`
+	}{
+		Path:         jsonText(p.fileName),
+		BinaryOffset: binaryOffset,
+		Stats:        stats,
+	})
+}
+
+// PrintJSONSummary выводит итоговую запись со статистикой по всем файлам и общим временем работы
+func PrintJSONSummary(writer io.Writer, elapsed time.Duration, stats Stats) error {
+	_, err := writeJSON(writer, "summary", struct {
+		ElapsedTotal jsonDuration `json:"elapsed_total"`
+		Stats        Stats        `json:"stats"`
+	}{
+		ElapsedTotal: jsonDuration(elapsed),
+		Stats:        stats,
+	})
+
+	return err
+}
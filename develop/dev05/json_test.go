@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeJSONLines разбирает вывод --json, заменяя длительности нулями, чтобы вывод можно было сравнивать
+func decodeJSONLines(t *testing.T, output string) []map[string]any {
+	var records []map[string]any
+
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+
+		record := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid JSON %q: %v", line, err)
+		}
+
+		if data, ok := record["data"].(map[string]any); ok {
+			if stats, ok := data["stats"].(map[string]any); ok {
+				delete(stats, "elapsed")
+			}
+
+			delete(data, "elapsed_total")
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func TestPrinter_PrintJSON(t *testing.T) {
+	printer := &Printer{linesAfter: 1, fileName: "a.txt", json: true}
+	search := &Search{predicate: mustPredicate(t, "error")}
+
+	buf := &strings.Builder{}
+	n, err := printer.Print(search, strings.NewReader("alpha\nbeta <error>\r\ngamma\nlast error"), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := map[string]any{"text": "a.txt"}
+	expected := []map[string]any{
+		{"type": "begin", "data": map[string]any{"path": path}},
+		{"type": "match", "data": map[string]any{
+			"path":            path,
+			"lines":           map[string]any{"text": "beta <error>\r\n"},
+			"line_number":     2.0,
+			"absolute_offset": 6.0,
+			"submatches": []any{
+				map[string]any{"match": map[string]any{"text": "error"}, "start": 6.0, "end": 11.0},
+			},
+		}},
+		{"type": "context", "data": map[string]any{
+			"path":            path,
+			"lines":           map[string]any{"text": "gamma\n"},
+			"line_number":     3.0,
+			"absolute_offset": 20.0,
+			"submatches":      []any{},
+		}},
+		{"type": "match", "data": map[string]any{
+			"path":            path,
+			"lines":           map[string]any{"text": "last error"},
+			"line_number":     4.0,
+			"absolute_offset": 26.0,
+			"submatches": []any{
+				map[string]any{"match": map[string]any{"text": "error"}, "start": 5.0, "end": 10.0},
+			},
+		}},
+	}
+
+	records := decodeJSONLines(t, buf.String())
+	if len(records) != len(expected)+1 {
+		t.Fatalf("unexpected number of records: %d\n%s", len(records), buf.String())
+	}
+
+	for i, e := range expected {
+		if !reflect.DeepEqual(records[i], e) {
+			t.Errorf("unexpected record %d: %v (expected %v)", i, records[i], e)
+		}
+	}
+
+	// Статистика в записи end совпадает со статистикой, которая попадёт в summary
+	end := records[len(records)-1]
+	if end["type"] != "end" {
+		t.Fatalf("unexpected last record: %v", end)
+	}
+
+	// В bytes_printed не входит сама запись end
+	output := buf.String()
+	endRecord := output[strings.LastIndex(strings.TrimSuffix(output, "\n"), "\n")+1:]
+
+	stats := end["data"].(map[string]any)["stats"]
+	expectedStats := map[string]any{
+		"searches":            1.0,
+		"searches_with_match": 1.0,
+		"bytes_searched":      36.0,
+		"bytes_printed":       float64(n - len(endRecord)),
+		"matched_lines":       2.0,
+		"matches":             2.0,
+	}
+
+	if !reflect.DeepEqual(stats, expectedStats) {
+		t.Errorf("unexpected stats: %v (expected %v)", stats, expectedStats)
+	}
+
+	if search.stats.MatchedLines != 2 || search.stats.BytesSearched != 36 {
+		t.Errorf("unexpected search stats: %+v", search.stats)
+	}
+
+	// Для файла без найденных строк ничего не выводится, но статистика собирается
+	buf.Reset()
+	search = &Search{predicate: mustPredicate(t, "missing")}
+	if _, err := printer.Print(search, strings.NewReader("alpha\n"), buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+
+	if search.stats.Searches != 1 || search.stats.SearchesWithMatch != 0 || search.stats.BytesSearched != 6 {
+		t.Errorf("unexpected search stats: %+v", search.stats)
+	}
+}
+
+func TestPrintJSONSummary(t *testing.T) {
+	stats := Stats{Searches: 1, SearchesWithMatch: 1, MatchedLines: 2, Matches: 3}
+	stats.Add(Stats{Searches: 1, BytesSearched: 10})
+
+	buf := &strings.Builder{}
+	if err := PrintJSONSummary(buf, 1500*time.Millisecond, stats); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"type":"summary","data":{"elapsed_total":{"secs":1,"nanos":500000000,"human":"1.500000s"},` +
+		`"stats":{"elapsed":{"secs":0,"nanos":0,"human":"0.000000s"},"searches":2,"searches_with_match":1,` +
+		`"bytes_searched":10,"bytes_printed":0,"matched_lines":2,"matches":3}}}` + "\n"
+
+	if actual := buf.String(); actual != expected {
+		t.Errorf("unexpected summary:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
+
+func TestJSONText(t *testing.T) {
+	tests := map[jsonText]string{
+		"a <b> & c": `{"text":"a <b> & c"}`,
+		"\xff\xfe":  `{"bytes":"//4="}`,
+		"":          `{"text":""}`,
+	}
+
+	for text, expected := range tests {
+		actual, err := marshalJSON(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(actual) != expected {
+			t.Errorf("unexpected JSON for %q: %s (expected %s)", text, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PatternOptions - параметры сопоставления строк с шаблонами
+type PatternOptions struct {
+	// Шаблоны - обычные строки, а не регулярные выражения (-F)
+	Fixed bool
+
+	// Игнорировать регистр (-i)
+	IgnoreCase bool
+
+	// Шаблон должен совпадать со всей строкой (-x)
+	WholeLine bool
+
+	// Шаблон должен совпадать с целым словом (-w)
+	WholeWord bool
+
+	// Шаблоны - регулярные выражения в стиле PCRE (-P)
+	Perl bool
+
+	// Бюджет шагов на одну строку для -P
+	BacktrackLimit int
+}
+
+// errConflictingMatchers сообщает, что одновременно заданы -F и -P
+var errConflictingMatchers = errors.New("conflicting matchers specified")
+
+// CompilePatterns создаёт функцию для проверки строк, которая ищет в строке любой из шаблонов. Регулярные выражения
+// объединяются в одно и сопоставляются по правилу самого левого и самого длинного совпадения, как в POSIX. Обычные
+// строки ищутся за один проход автоматом Ахо-Корасик, поэтому их может быть много.
+func CompilePatterns(patterns []string, options PatternOptions) (LinePredicate, error) {
+	// Без шаблонов ни одна строка не подходит (например, для пустого файла в -f)
+	if len(patterns) == 0 {
+		return func(string) []Span {
+			return nil
+		}, nil
+	}
+
+	if options.Fixed && options.Perl {
+		return nil, errConflictingMatchers
+	}
+
+	if options.Perl {
+		return compilePerlPredicate(patterns, options)
+	}
+
+	if options.Fixed {
+		matcher := NewAhoCorasick(patterns, options.IgnoreCase)
+
+		return func(s string) []Span {
+			return matcher.FindAll(s, func(start, end int) bool {
+				switch {
+				case options.WholeLine:
+					return start == 0 && end == len(s)
+				case options.WholeWord:
+					return isWord(s, start, end)
+				default:
+					return true
+				}
+			})
+		}, nil
+	}
+
+	source := "(?:" + strings.Join(patterns, ")|(?:") + ")"
+	if options.WholeLine {
+		source = "^(?:" + source + ")$"
+	}
+
+	// Если нужно игнорировать регистр, то добавляем (?i) в начало шаблона
+	if options.IgnoreCase {
+		source = "(?i)" + source
+	}
+
+	// При -x совпадение со всей строкой всегда является целым словом
+	if options.WholeWord && !options.WholeLine {
+		matcher, err := newWordMatcher(source)
+		if err != nil {
+			return nil, err
+		}
+
+		return matcher.FindAll, nil
+	}
+
+	p, err := regexp.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Longest()
+
+	return func(s string) []Span {
+		matches := p.FindAllStringIndex(s, -1)
+		if matches == nil {
+			return nil
+		}
+
+		spans := make([]Span, len(matches))
+		for i, m := range matches {
+			spans[i] = Span{Start: m[0], End: m[1]}
+		}
+
+		return spans
+	}, nil
+}
+
+// wordMatcher ищет совпадения регулярного выражения, являющиеся целыми словами (-w), так же, как GNU grep: если самое
+// длинное совпадение в позиции не является словом, пробуются более короткие совпадения в той же позиции, а затем поиск
+// продолжается со следующего символа. Так находятся слова, перекрытые более длинными неподходящими совпадениями.
+//
+// Пакет regexp не умеет начинать поиск с середины строки, поэтому поиск продолжается в подстроке. Чтобы "^" и "$" не
+// совпадали на её границах, для подстрок используются варианты шаблона, в которых эти якоря ничему не соответствуют.
+// \b и \B на границах подстроки ведут себя так же, как в целой строке: совпадение принимается, только если снаружи от
+// границы стоит символ, не входящий в слово.
+type wordMatcher struct {
+	// Поиск с начала строки и с середины строки
+	search, searchInner *regexp.Regexp
+
+	// Проверка, что фрагмент от начала строки или от её середины целиком совпадает с шаблоном
+	exact, exactInner *regexp.Regexp
+}
+
+func newWordMatcher(source string) (*wordMatcher, error) {
+	tree, err := syntax.Parse(source, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+
+	noBegin := withoutAnchors(tree, syntax.OpBeginText, syntax.OpBeginLine)
+	noEnd := withoutAnchors(tree, syntax.OpEndText, syntax.OpEndLine)
+	noAnchors := withoutAnchors(noEnd, syntax.OpBeginText, syntax.OpBeginLine)
+
+	m := &wordMatcher{}
+	for _, c := range []struct {
+		target  **regexp.Regexp
+		tree    *syntax.Regexp
+		longest bool
+	}{
+		{&m.search, tree, true},
+		{&m.searchInner, noBegin, true},
+		{&m.exact, wholeText(noEnd), false},
+		{&m.exactInner, wholeText(noAnchors), false},
+	} {
+		*c.target, err = regexp.Compile(c.tree.String())
+		if err != nil {
+			return nil, err
+		}
+
+		if c.longest {
+			(*c.target).Longest()
+		}
+	}
+
+	return m, nil
+}
+
+// withoutAnchors возвращает копию дерева шаблона, в которой узлы с операциями ops заменены на узлы, ничему не
+// соответствующие
+func withoutAnchors(tree *syntax.Regexp, ops ...syntax.Op) *syntax.Regexp {
+	for _, op := range ops {
+		if tree.Op == op {
+			return &syntax.Regexp{Op: syntax.OpNoMatch}
+		}
+	}
+
+	c := *tree
+	c.Sub = make([]*syntax.Regexp, len(tree.Sub))
+	for i, sub := range tree.Sub {
+		c.Sub[i] = withoutAnchors(sub, ops...)
+	}
+
+	return &c
+}
+
+// wholeText оборачивает шаблон так, чтобы он совпадал только с текстом целиком
+func wholeText(tree *syntax.Regexp) *syntax.Regexp {
+	return &syntax.Regexp{
+		Op:  syntax.OpConcat,
+		Sub: []*syntax.Regexp{{Op: syntax.OpBeginText}, tree, {Op: syntax.OpEndText}},
+	}
+}
+
+// FindAll возвращает все непересекающиеся совпадения в строке s, являющиеся целыми словами
+func (m *wordMatcher) FindAll(s string) []Span {
+	var spans []Span
+
+	for pos := 0; pos <= len(s); {
+		search := m.search
+		if pos > 0 {
+			search = m.searchInner
+		}
+
+		loc := search.FindStringIndex(s[pos:])
+		if loc == nil {
+			break
+		}
+
+		start, end := pos+loc[0], pos+loc[1]
+		if found, ok := m.wordAt(s, start, end); ok {
+			spans = append(spans, Span{Start: start, End: found})
+
+			if found > start {
+				pos = found
+				continue
+			}
+		}
+
+		// Совпадение не подошло или оказалось пустым: продолжаем со следующего символа
+		if start == len(s) {
+			break
+		}
+
+		_, size := utf8.DecodeRuneInString(s[start:])
+		pos = start + size
+	}
+
+	return spans
+}
+
+// wordAt ищет среди совпадений, начинающихся в позиции start и заканчивающихся не дальше end, самое длинное, которое
+// является целым словом, и возвращает его конец
+func (m *wordMatcher) wordAt(s string, start, end int) (int, bool) {
+	if wordRuneBefore(s, start) {
+		return 0, false
+	}
+
+	if !wordRuneAfter(s, end) {
+		return end, true
+	}
+
+	exact := m.exact
+	if start > 0 {
+		exact = m.exactInner
+	}
+
+	for e := end - 1; e >= start; e-- {
+		if utf8.RuneStart(s[e]) && !wordRuneAfter(s, e) && exact.MatchString(s[start:e]) {
+			return e, true
+		}
+	}
+
+	return 0, false
+}
+
+// compilePerlPredicate создаёт функцию для проверки строк шаблонами -P. Условия -x и -w добавляются в сами шаблоны,
+// как это делает GNU grep. Если на строке исчерпан бюджет шагов, функция паникует с *BacktrackLimitError: интерфейс
+// LinePredicate не позволяет вернуть ошибку, поэтому её перехватывает Search.Match.
+func compilePerlPredicate(patterns []string, options PatternOptions) (LinePredicate, error) {
+	wrapped := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		switch {
+		case options.WholeLine:
+			wrapped[i] = "^(?:" + pattern + ")$"
+		case options.WholeWord:
+			wrapped[i] = `(?<!\w)(?:` + pattern + `)(?!\w)`
+		default:
+			wrapped[i] = pattern
+		}
+	}
+
+	limit := options.BacktrackLimit
+	if limit <= 0 {
+		limit = DefaultBacktrackLimit
+	}
+
+	perl, err := CompilePerl(wrapped, options.IgnoreCase, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(s string) []Span {
+		spans, err := perl.FindAll(s)
+		if err != nil {
+			panic(err)
+		}
+
+		return spans
+	}, nil
+}
+
+// isWordRune проверяет, может ли руна входить в слово: буквы, цифры и знак подчёркивания
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isWord проверяет, что фрагмент s[start:end] не окружён символами слова, то есть является целым словом
+func isWord(s string, start, end int) bool {
+	return !wordRuneBefore(s, start) && !wordRuneAfter(s, end)
+}
+
+// wordRuneBefore проверяет, что перед позицией i в строке s стоит символ слова
+func wordRuneBefore(s string, i int) bool {
+	r, size := utf8.DecodeLastRuneInString(s[:i])
+	return size > 0 && isWordRune(r)
+}
+
+// wordRuneAfter проверяет, что в позиции i в строке s стоит символ слова
+func wordRuneAfter(s string, i int) bool {
+	r, size := utf8.DecodeRuneInString(s[i:])
+	return size > 0 && isWordRune(r)
+}
+
+// SplitPatterns разбивает шаблоны, содержащие переводы строк, на отдельные шаблоны, как это делает grep
+func SplitPatterns(patterns []string) []string {
+	var result []string
+	for _, pattern := range patterns {
+		result = append(result, strings.Split(pattern, "\n")...)
+	}
+
+	return result
+}
+
+// ReadPatterns читает шаблоны из файла, по одному на строку. Путь "-" означает stdin.
+func ReadPatterns(path string) ([]string, error) {
+	var reader io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer file.Close()
+		reader = file
+	}
+
+	var patterns []string
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 1<<30)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompilePatterns(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		options  PatternOptions
+		line     string
+		expected []Span
+	}{
+		{
+			// -F ищет подстроку, а не совпадение со всей строкой
+			patterns: []string{"a.c"},
+			options:  PatternOptions{Fixed: true},
+			line:     "xa.cx abc",
+			expected: []Span{{Start: 1, End: 4}},
+		},
+		{
+			patterns: []string{"foo"},
+			options:  PatternOptions{Fixed: true, WholeLine: true},
+			line:     "foo bar",
+			expected: nil,
+		},
+		{
+			patterns: []string{"bar", "foo bar"},
+			options:  PatternOptions{Fixed: true, WholeLine: true, IgnoreCase: true},
+			line:     "FOO bar",
+			expected: []Span{{Start: 0, End: 7}},
+		},
+		{
+			patterns: []string{"bar"},
+			options:  PatternOptions{Fixed: true, WholeWord: true},
+			line:     "foobar bar_x (bar)",
+			expected: []Span{{Start: 14, End: 17}},
+		},
+		{
+			// Буквы не из ASCII тоже считаются символами слова
+			patterns: []string{"кот"},
+			options:  PatternOptions{WholeWord: true},
+			line:     "котёнок кот",
+			expected: []Span{{Start: 15, End: 21}},
+		},
+		{
+			// Регулярные выражения сопоставляются по правилу самого длинного совпадения
+			patterns: []string{"a|ab"},
+			line:     "ab",
+			expected: []Span{{Start: 0, End: 2}},
+		},
+		{
+			patterns: []string{"o+", "ba."},
+			options:  PatternOptions{WholeLine: true},
+			line:     "ooo",
+			expected: []Span{{Start: 0, End: 3}},
+		},
+		{
+			patterns: []string{"[0-9]+"},
+			options:  PatternOptions{WholeWord: true},
+			line:     "a1 22 b3",
+			expected: []Span{{Start: 3, End: 5}},
+		},
+		{
+			// Слово "b" перекрыто более длинным совпадением "xa b", которое не является словом
+			patterns: []string{"xa b", "b"},
+			options:  PatternOptions{WholeWord: true},
+			line:     "zxa b",
+			expected: []Span{{Start: 4, End: 5}},
+		},
+		{
+			// Самое длинное совпадение в позиции не является словом, но более короткое - является
+			patterns: []string{"a", "a-b"},
+			options:  PatternOptions{WholeWord: true},
+			line:     "a-bc",
+			expected: []Span{{Start: 0, End: 1}},
+		},
+		{
+			// При повторном поиске с середины строки "^" не должен совпадать в начале подстроки
+			patterns: []string{"a1", "^b"},
+			options:  PatternOptions{WholeWord: true},
+			line:     "a1b b",
+			expected: nil,
+		},
+		{
+			patterns: nil,
+			line:     "anything",
+			expected: nil,
+		},
+	}
+
+	for _, c := range tests {
+		predicate, err := CompilePatterns(c.patterns, c.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual := predicate(c.line)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%q %+v in %q: unexpected spans %v (expected %v)", c.patterns, c.options, c.line, actual, c.expected)
+		}
+	}
+
+	if _, err := CompilePatterns([]string{"ok", "(unclosed"}, PatternOptions{}); err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+}
+
+func TestReadPatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patterns")
+	if err := os.WriteFile(path, []byte("foo\n\nbar\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns, err := ReadPatterns(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(patterns, []string{"foo", "", "bar"}) {
+		t.Errorf("unexpected patterns: %q", patterns)
+	}
+
+	if actual := SplitPatterns([]string{"a\nb", "c"}); !reflect.DeepEqual(actual, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected split patterns: %q", actual)
+	}
+}
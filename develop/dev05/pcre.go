@@ -0,0 +1,1446 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultBacktrackLimit - сколько шагов движок -P может сделать при проверке одной строки
+const DefaultBacktrackLimit = 1000000
+
+// maxRepeat - наибольшее допустимое число повторений в квантификаторе {n,m}
+const maxRepeat = 1000
+
+// BacktrackLimitError сообщает, что при проверке строки движок -P исчерпал бюджет шагов. Так защищаемся от шаблонов с
+// катастрофическим перебором вроде (a+)+$.
+type BacktrackLimitError struct {
+	Limit int
+}
+
+func (e *BacktrackLimitError) Error() string {
+	return fmt.Sprintf("exceeded backtracking limit of %d steps", e.Limit)
+}
+
+// Perl - регулярные выражения в стиле PCRE, которые проверяются перебором с возвратами. В отличие от regexp (RE2),
+// поддерживаются обратные ссылки (\1, \k<name>), опережающие и ретроспективные проверки ((?=...), (?!...), (?<=...),
+// (?<!...)) и \K. Время работы на одной строке ограничено бюджетом шагов. Совпадения выбираются как в Perl: первая
+// подходящая альтернатива, а не самая длинная. Классы \w, \d, \s и граница слова \b учитывают Unicode.
+type Perl struct {
+	programs []*perlProgram
+	limit    int
+}
+
+// CompilePerl компилирует шаблоны для -P. Если ignoreCase, регистр не учитывается. limit - бюджет шагов на строку.
+func CompilePerl(patterns []string, ignoreCase bool, limit int) (*Perl, error) {
+	p := &Perl{limit: limit}
+
+	for _, pattern := range patterns {
+		parser := &perlParser{source: []rune(pattern), fold: ignoreCase, names: map[string]int{}}
+
+		node, err := parser.parse()
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", pattern, err)
+		}
+
+		program, err := compilePerl(node, parser.groups)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", pattern, err)
+		}
+
+		p.programs = append(p.programs, program)
+	}
+
+	return p, nil
+}
+
+// FindAll находит в text непересекающиеся совпадения любого из шаблонов. В каждой позиции шаблоны пробуются по
+// порядку. Если бюджет шагов исчерпан, возвращается *BacktrackLimitError.
+func (p *Perl) FindAll(text string) ([]Span, error) {
+	m := &perlMachine{text: text, limit: p.limit}
+
+	var spans []Span
+	lastEnd := -1
+
+	for start := 0; start <= len(text); {
+		span, ok := p.matchAt(m, start)
+		if m.exceeded {
+			return nil, &BacktrackLimitError{Limit: p.limit}
+		}
+
+		// Как в regexp, пустое совпадение сразу после предыдущего не учитывается
+		if ok && !(span.Start == span.End && span.End == lastEnd) {
+			spans = append(spans, span)
+			lastEnd = span.End
+		}
+
+		if ok && span.End > start {
+			start = span.End
+			continue
+		}
+
+		if start == len(text) {
+			break
+		}
+
+		_, size := utf8.DecodeRuneInString(text[start:])
+		start += size
+	}
+
+	if spans == nil {
+		return nil, nil
+	}
+
+	return spans, nil
+}
+
+// matchAt пробует шаблоны по порядку, начиная с позиции start
+func (p *Perl) matchAt(m *perlMachine, start int) (Span, bool) {
+	for _, program := range p.programs {
+		regs := make([]int, program.slots)
+		for i := range regs {
+			regs[i] = -1
+		}
+
+		if _, ok := m.run(program.insts, start, regs); ok {
+			return Span{Start: regs[0], End: regs[1]}, true
+		}
+
+		if m.exceeded {
+			break
+		}
+	}
+
+	return Span{}, false
+}
+
+// perlKind - вид узла дерева разбора
+type perlKind int
+
+const (
+	perlEmpty perlKind = iota
+	perlLiteral
+	perlAny
+	perlClass
+	perlConcat
+	perlAlternate
+	perlRepeat
+	perlCapture
+	perlBackref
+	perlAssert
+	perlLook
+	perlKeep
+)
+
+// perlAssertKind - вид проверки позиции, не поглощающей символов
+type perlAssertKind int
+
+const (
+	assertLineStart perlAssertKind = iota
+	assertLineEnd
+	assertWordBoundary
+	assertNotWordBoundary
+)
+
+// perlNode - узел дерева разбора шаблона
+type perlNode struct {
+	kind perlKind
+
+	// Символ для perlLiteral
+	r rune
+
+	// Учитывать регистр символа, класса или обратной ссылки?
+	fold bool
+
+	// Класс символов для perlClass
+	class *runeClass
+
+	// Вложенные узлы
+	subs []*perlNode
+
+	// Границы повторения для perlRepeat; max < 0 - без ограничения
+	min, max int
+
+	// Жадное ли повторение
+	greedy bool
+
+	// Номер группы для perlCapture и perlBackref
+	index int
+
+	// Вид проверки для perlAssert
+	assert perlAssertKind
+
+	// Для perlLook: опережающая ли проверка и отрицательная ли
+	ahead, negate bool
+}
+
+// runeClass - класс символов: набор диапазонов и таблиц Unicode, возможно, с отрицанием
+type runeClass struct {
+	ranges []rune
+	tables []*unicode.RangeTable
+
+	// Таблицы, символы которых в класс не входят (например, \W внутри [...])
+	notTables []*unicode.RangeTable
+
+	// Функции для \w, \d, \s и их отрицаний
+	funcs []func(rune) bool
+
+	negate bool
+}
+
+// matches проверяет, входит ли руна в класс. Если fold, учитываются все варианты регистра руны.
+func (c *runeClass) matches(r rune, fold bool) bool {
+	in := c.contains(r)
+	if !in && fold {
+		for f := unicode.SimpleFold(r); f != r && !in; f = unicode.SimpleFold(f) {
+			in = c.contains(f)
+		}
+	}
+
+	return in != c.negate
+}
+
+func (c *runeClass) contains(r rune) bool {
+	for i := 0; i+1 < len(c.ranges); i += 2 {
+		if c.ranges[i] <= r && r <= c.ranges[i+1] {
+			return true
+		}
+	}
+
+	for _, t := range c.tables {
+		if unicode.Is(t, r) {
+			return true
+		}
+	}
+
+	for _, t := range c.notTables {
+		if !unicode.Is(t, r) {
+			return true
+		}
+	}
+
+	for _, f := range c.funcs {
+		if f(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isDigitRune(r rune) bool {
+	return unicode.IsDigit(r)
+}
+
+func isSpaceRune(r rune) bool {
+	return unicode.IsSpace(r)
+}
+
+// not возвращает отрицание функции-класса
+func not(f func(rune) bool) func(rune) bool {
+	return func(r rune) bool {
+		return !f(r)
+	}
+}
+
+// posixClasses - классы вида [:alpha:] внутри квадратных скобок
+var posixClasses = map[string]func(rune) bool{
+	"alpha":  unicode.IsLetter,
+	"digit":  func(r rune) bool { return '0' <= r && r <= '9' },
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"upper":  unicode.IsUpper,
+	"lower":  unicode.IsLower,
+	"space":  unicode.IsSpace,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"punct":  unicode.IsPunct,
+	"xdigit": func(r rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", r) },
+	"word":   isWordRune,
+	"cntrl":  unicode.IsControl,
+	"print":  unicode.IsPrint,
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+}
+
+// perlParser разбирает шаблон методом рекурсивного спуска
+type perlParser struct {
+	source []rune
+	pos    int
+
+	// Текущий режим без учёта регистра ((?i) меняет его до конца группы)
+	fold bool
+
+	// Количество захватывающих групп и их имена
+	groups int
+	names  map[string]int
+}
+
+func (p *perlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *perlParser) more() bool {
+	return p.pos < len(p.source)
+}
+
+func (p *perlParser) peek() rune {
+	return p.source[p.pos]
+}
+
+// consume пропускает prefix, если шаблон продолжается им
+func (p *perlParser) consume(prefix string) bool {
+	runes := []rune(prefix)
+	if p.pos+len(runes) > len(p.source) {
+		return false
+	}
+
+	for i, r := range runes {
+		if p.source[p.pos+i] != r {
+			return false
+		}
+	}
+
+	p.pos += len(runes)
+	return true
+}
+
+func (p *perlParser) parse() (*perlNode, error) {
+	node, err := p.parseAlternate()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.more() {
+		return nil, p.errorf("unmatched )")
+	}
+
+	return node, nil
+}
+
+// parseAlternate разбирает альтернативы, разделённые |
+func (p *perlParser) parseAlternate() (*perlNode, error) {
+	// Флаги, заданные внутри группы, действуют только до её конца
+	fold := p.fold
+	defer func() {
+		p.fold = fold
+	}()
+
+	var branches []*perlNode
+	for {
+		branch, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+
+		branches = append(branches, branch)
+		if !p.consume("|") {
+			break
+		}
+	}
+
+	if len(branches) == 1 {
+		return branches[0], nil
+	}
+
+	return &perlNode{kind: perlAlternate, subs: branches}, nil
+}
+
+// parseConcat разбирает последовательность элементов до | или )
+func (p *perlParser) parseConcat() (*perlNode, error) {
+	node := &perlNode{kind: perlConcat}
+
+	for p.more() && p.peek() != '|' && p.peek() != ')' {
+		atom, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+
+		if atom == nil {
+			continue
+		}
+
+		atom, err = p.parseQuantifier(atom)
+		if err != nil {
+			return nil, err
+		}
+
+		node.subs = append(node.subs, atom)
+	}
+
+	return node, nil
+}
+
+// parseQuantifier разбирает квантификаторы после элемента
+func (p *perlParser) parseQuantifier(atom *perlNode) (*perlNode, error) {
+	for p.more() {
+		min, max := 0, 0
+
+		switch p.peek() {
+		case '*':
+			min, max = 0, -1
+			p.pos++
+		case '+':
+			min, max = 1, -1
+			p.pos++
+		case '?':
+			min, max = 0, 1
+			p.pos++
+		case '{':
+			var ok bool
+			min, max, ok = p.parseBraces()
+			if !ok {
+				// Как в PCRE, { без правильного квантификатора - обычный символ
+				return atom, nil
+			}
+		default:
+			return atom, nil
+		}
+
+		if min > maxRepeat || max > maxRepeat {
+			return nil, p.errorf("repetition count exceeds %d", maxRepeat)
+		}
+
+		if max >= 0 && min > max {
+			return nil, p.errorf("invalid repetition range {%d,%d}", min, max)
+		}
+
+		switch atom.kind {
+		case perlAssert, perlLook, perlKeep, perlEmpty:
+			return nil, p.errorf("nothing to repeat")
+		}
+
+		greedy := true
+		if p.consume("?") {
+			greedy = false
+		} else if p.more() && p.peek() == '+' {
+			return nil, p.errorf("possessive quantifiers are not supported")
+		}
+
+		atom = &perlNode{kind: perlRepeat, subs: []*perlNode{atom}, min: min, max: max, greedy: greedy}
+	}
+
+	return atom, nil
+}
+
+// parseBraces разбирает квантификатор {n}, {n,} или {n,m}. Если это не квантификатор, позиция не меняется.
+func (p *perlParser) parseBraces() (min, max int, ok bool) {
+	start := p.pos
+	end := start + 1
+	for end < len(p.source) && p.source[end] != '}' {
+		end++
+	}
+
+	if end == len(p.source) {
+		return 0, 0, false
+	}
+
+	body := string(p.source[start+1 : end])
+	low, high, hasComma := strings.Cut(body, ",")
+
+	min, err := strconv.Atoi(low)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	max = min
+	if hasComma {
+		if high == "" {
+			max = -1
+		} else if max, err = strconv.Atoi(high); err != nil {
+			return 0, 0, false
+		}
+	}
+
+	p.pos = end + 1
+	return min, max, true
+}
+
+// parseAtom разбирает один элемент шаблона. Для конструкций, которые ничего не добавляют в шаблон (например, (?i)),
+// возвращается nil.
+func (p *perlParser) parseAtom() (*perlNode, error) {
+	r := p.peek()
+	p.pos++
+
+	switch r {
+	case '(':
+		return p.parseGroup()
+	case '[':
+		return p.parseClass()
+	case '\\':
+		return p.parseEscape(false)
+	case '.':
+		return &perlNode{kind: perlAny}, nil
+	case '^':
+		return &perlNode{kind: perlAssert, assert: assertLineStart}, nil
+	case '$':
+		return &perlNode{kind: perlAssert, assert: assertLineEnd}, nil
+	case '*', '+', '?':
+		return nil, p.errorf("nothing to repeat")
+	default:
+		return &perlNode{kind: perlLiteral, r: r, fold: p.fold}, nil
+	}
+}
+
+// parseGroup разбирает группу после открывающей скобки
+func (p *perlParser) parseGroup() (*perlNode, error) {
+	var node *perlNode
+
+	switch {
+	case p.consume("?:"):
+		node = &perlNode{kind: perlConcat}
+	case p.consume("?="):
+		node = &perlNode{kind: perlLook, ahead: true}
+	case p.consume("?!"):
+		node = &perlNode{kind: perlLook, ahead: true, negate: true}
+	case p.consume("?<="):
+		node = &perlNode{kind: perlLook}
+	case p.consume("?<!"):
+		node = &perlNode{kind: perlLook, negate: true}
+	case p.consume("?P<"), p.consume("?<"), p.consume("?'"):
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := p.names[name]; ok {
+			return nil, p.errorf("duplicate group name %q", name)
+		}
+
+		p.groups++
+		p.names[name] = p.groups
+		node = &perlNode{kind: perlCapture, index: p.groups}
+	case p.consume("?"):
+		return p.parseFlags()
+	default:
+		p.groups++
+		node = &perlNode{kind: perlCapture, index: p.groups}
+	}
+
+	sub, err := p.parseAlternate()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.consume(")") {
+		return nil, p.errorf("missing )")
+	}
+
+	node.subs = []*perlNode{sub}
+	return node, nil
+}
+
+// parseName разбирает имя группы до > или '
+func (p *perlParser) parseName() (string, error) {
+	start := p.pos
+	for p.more() && p.peek() != '>' && p.peek() != '\'' && p.peek() != '}' {
+		r := p.peek()
+		if !isWordRune(r) {
+			return "", p.errorf("invalid character %q in group name", r)
+		}
+
+		p.pos++
+	}
+
+	if !p.more() || p.pos == start {
+		return "", p.errorf("invalid group name")
+	}
+
+	name := string(p.source[start:p.pos])
+	p.pos++
+	return name, nil
+}
+
+// parseFlags разбирает (?i), (?-i) и (?i:...). Остальные флаги не влияют на поиск в одной строке и пропускаются.
+func (p *perlParser) parseFlags() (*perlNode, error) {
+	enable := true
+	fold := p.fold
+
+	for p.more() {
+		r := p.peek()
+		p.pos++
+
+		switch r {
+		case '-':
+			enable = false
+		case 'i':
+			fold = enable
+		case 's', 'm', 'x', 'U', 'u':
+			if r == 'x' || r == 'U' {
+				return nil, p.errorf("flag %q is not supported", r)
+			}
+		case ')':
+			// Флаги действуют до конца текущей группы
+			p.fold = fold
+			return nil, nil
+		case ':':
+			saved := p.fold
+			p.fold = fold
+
+			sub, err := p.parseAlternate()
+			p.fold = saved
+			if err != nil {
+				return nil, err
+			}
+
+			if !p.consume(")") {
+				return nil, p.errorf("missing )")
+			}
+
+			return &perlNode{kind: perlConcat, subs: []*perlNode{sub}}, nil
+		default:
+			return nil, p.errorf("unknown group flag %q", r)
+		}
+	}
+
+	return nil, p.errorf("missing )")
+}
+
+// parseClass разбирает класс символов после открывающей квадратной скобки
+func (p *perlParser) parseClass() (*perlNode, error) {
+	class := &runeClass{}
+	if p.consume("^") {
+		class.negate = true
+	}
+
+	first := true
+	for {
+		if !p.more() {
+			return nil, p.errorf("missing ]")
+		}
+
+		// ] в самом начале класса - обычный символ
+		if p.peek() == ']' && !first {
+			p.pos++
+			break
+		}
+
+		first = false
+
+		if p.consume("[:") {
+			end := p.pos
+			for end+1 < len(p.source) && !(p.source[end] == ':' && p.source[end+1] == ']') {
+				end++
+			}
+
+			name := string(p.source[p.pos:end])
+			negate := strings.HasPrefix(name, "^")
+
+			f, ok := posixClasses[strings.TrimPrefix(name, "^")]
+			if !ok || end+1 >= len(p.source) {
+				return nil, p.errorf("unknown POSIX class %q", name)
+			}
+
+			if negate {
+				f = not(f)
+			}
+
+			class.funcs = append(class.funcs, f)
+			p.pos = end + 2
+			continue
+		}
+
+		low, err := p.parseClassRune(class)
+		if err != nil {
+			return nil, err
+		}
+
+		if low < 0 {
+			continue
+		}
+
+		high := low
+		if p.pos+1 < len(p.source) && p.peek() == '-' && p.source[p.pos+1] != ']' {
+			p.pos++
+
+			high, err = p.parseClassRune(class)
+			if err != nil {
+				return nil, err
+			}
+
+			if high < 0 || high < low {
+				return nil, p.errorf("invalid range in character class")
+			}
+		}
+
+		class.ranges = append(class.ranges, low, high)
+	}
+
+	return &perlNode{kind: perlClass, class: class, fold: p.fold}, nil
+}
+
+// parseClassRune разбирает один символ внутри класса. Если это был вложенный класс (\d, \p{L}), он добавляется в
+// class и возвращается -1.
+func (p *perlParser) parseClassRune(class *runeClass) (rune, error) {
+	r := p.peek()
+	p.pos++
+
+	if r != '\\' {
+		return r, nil
+	}
+
+	node, err := p.parseEscape(true)
+	if err != nil {
+		return 0, err
+	}
+
+	switch node.kind {
+	case perlLiteral:
+		return node.r, nil
+	case perlClass:
+		sub := node.class
+		if sub.negate {
+			// Отрицание вложенного класса: подходят символы, которые не входят в него
+			class.funcs = append(class.funcs, func(r rune) bool {
+				return !sub.contains(r)
+			})
+		} else {
+			class.funcs = append(class.funcs, sub.contains)
+		}
+
+		return -1, nil
+	default:
+		return 0, p.errorf("invalid escape in character class")
+	}
+}
+
+// parseEscape разбирает последовательность после обратной косой черты. inClass - находимся ли внутри [...].
+func (p *perlParser) parseEscape(inClass bool) (*perlNode, error) {
+	if !p.more() {
+		return nil, p.errorf("trailing backslash")
+	}
+
+	r := p.peek()
+	p.pos++
+
+	literal := func(r rune) (*perlNode, error) {
+		return &perlNode{kind: perlLiteral, r: r, fold: p.fold}, nil
+	}
+
+	class := func(f func(rune) bool, negate bool) (*perlNode, error) {
+		return &perlNode{kind: perlClass, class: &runeClass{funcs: []func(rune) bool{f}, negate: negate}}, nil
+	}
+
+	switch r {
+	case 'd', 'D':
+		return class(isDigitRune, r == 'D')
+	case 'w', 'W':
+		return class(isWordRune, r == 'W')
+	case 's', 'S':
+		return class(isSpaceRune, r == 'S')
+	case 'p', 'P':
+		return p.parseUnicodeClass(r == 'P')
+	case 'n':
+		return literal('\n')
+	case 't':
+		return literal('\t')
+	case 'r':
+		return literal('\r')
+	case 'f':
+		return literal('\f')
+	case 'v':
+		return literal('\v')
+	case 'e':
+		return literal('\x1b')
+	case 'a':
+		return literal('\a')
+	case 'x':
+		return p.parseHex()
+	}
+
+	if inClass {
+		if r == 'b' {
+			return literal('\b')
+		}
+
+		if isWordRune(r) {
+			return nil, p.errorf("unknown escape \\%c", r)
+		}
+
+		return literal(r)
+	}
+
+	switch r {
+	case 'b':
+		return &perlNode{kind: perlAssert, assert: assertWordBoundary}, nil
+	case 'B':
+		return &perlNode{kind: perlAssert, assert: assertNotWordBoundary}, nil
+	case 'A':
+		return &perlNode{kind: perlAssert, assert: assertLineStart}, nil
+	case 'z', 'Z':
+		return &perlNode{kind: perlAssert, assert: assertLineEnd}, nil
+	case 'K':
+		return &perlNode{kind: perlKeep}, nil
+	case 'k':
+		return p.parseNamedBackref()
+	case 'g':
+		return p.parseNumberedBackref()
+	}
+
+	if '1' <= r && r <= '9' {
+		// Номер группы может состоять из нескольких цифр
+		index := int(r - '0')
+		for p.more() && '0' <= p.peek() && p.peek() <= '9' && index*10+int(p.peek()-'0') <= p.groups {
+			index = index*10 + int(p.peek()-'0')
+			p.pos++
+		}
+
+		return &perlNode{kind: perlBackref, index: index, fold: p.fold}, nil
+	}
+
+	if r == '0' {
+		return literal(0)
+	}
+
+	if isWordRune(r) {
+		return nil, p.errorf("unknown escape \\%c", r)
+	}
+
+	return literal(r)
+}
+
+// parseHex разбирает \xhh и \x{hhhh}
+func (p *perlParser) parseHex() (*perlNode, error) {
+	var digits string
+
+	if p.consume("{") {
+		start := p.pos
+		for p.more() && p.peek() != '}' {
+			p.pos++
+		}
+
+		if !p.more() {
+			return nil, p.errorf("missing }")
+		}
+
+		digits = string(p.source[start:p.pos])
+		p.pos++
+	} else {
+		start := p.pos
+		for p.more() && p.pos-start < 2 && strings.ContainsRune("0123456789abcdefABCDEF", p.peek()) {
+			p.pos++
+		}
+
+		digits = string(p.source[start:p.pos])
+	}
+
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || value > unicode.MaxRune {
+		return nil, p.errorf("invalid hexadecimal escape")
+	}
+
+	return &perlNode{kind: perlLiteral, r: rune(value), fold: p.fold}, nil
+}
+
+// parseUnicodeClass разбирает \p{Name}, \pL и их отрицания
+func (p *perlParser) parseUnicodeClass(negate bool) (*perlNode, error) {
+	if !p.more() {
+		return nil, p.errorf("missing Unicode class name")
+	}
+
+	var name string
+	if p.consume("{") {
+		start := p.pos
+		for p.more() && p.peek() != '}' {
+			p.pos++
+		}
+
+		if !p.more() {
+			return nil, p.errorf("missing }")
+		}
+
+		name = string(p.source[start:p.pos])
+		p.pos++
+	} else {
+		name = string(p.peek())
+		p.pos++
+	}
+
+	if strings.HasPrefix(name, "^") {
+		name = name[1:]
+		negate = !negate
+	}
+
+	table, ok := unicode.Categories[name]
+	if !ok {
+		table, ok = unicode.Scripts[name]
+	}
+
+	if !ok {
+		return nil, p.errorf("unknown Unicode class %q", name)
+	}
+
+	return &perlNode{kind: perlClass, class: &runeClass{tables: []*unicode.RangeTable{table}, negate: negate}}, nil
+}
+
+// parseNamedBackref разбирает \k<name>, \k'name' и \k{name}
+func (p *perlParser) parseNamedBackref() (*perlNode, error) {
+	if !p.consume("<") && !p.consume("'") && !p.consume("{") {
+		return nil, p.errorf("invalid named back reference")
+	}
+
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+
+	index, ok := p.names[name]
+	if !ok {
+		return nil, p.errorf("reference to non-existent group %q", name)
+	}
+
+	return &perlNode{kind: perlBackref, index: index, fold: p.fold}, nil
+}
+
+// parseNumberedBackref разбирает \gN и \g{N}
+func (p *perlParser) parseNumberedBackref() (*perlNode, error) {
+	braces := p.consume("{")
+
+	start := p.pos
+	for p.more() && '0' <= p.peek() && p.peek() <= '9' {
+		p.pos++
+	}
+
+	index, err := strconv.Atoi(string(p.source[start:p.pos]))
+	if err != nil || index == 0 {
+		return nil, p.errorf("invalid back reference")
+	}
+
+	if braces && !p.consume("}") {
+		return nil, p.errorf("missing }")
+	}
+
+	return &perlNode{kind: perlBackref, index: index, fold: p.fold}, nil
+}
+
+// perlOp - операция виртуальной машины
+type perlOp int
+
+const (
+	opRune perlOp = iota
+	opAny
+	opClass
+	opSplit
+	opJump
+	opSave
+	opNullCheck
+	opAssert
+	opBackref
+	opLook
+	opKeep
+	opMatch
+)
+
+// perlInst - инструкция виртуальной машины
+type perlInst struct {
+	op perlOp
+
+	r     rune
+	fold  bool
+	class *runeClass
+
+	// Переходы для opSplit (x - предпочтительный) и opJump, номер регистра для opSave и opNullCheck, номер группы
+	// для opBackref
+	x, y int
+
+	assert perlAssertKind
+	look   *perlLookaround
+}
+
+// perlLookaround - опережающая или ретроспективная проверка. Для ретроспективной проверки каждая альтернатива имеет
+// фиксированную длину и проверяется отдельно.
+type perlLookaround struct {
+	ahead, negate bool
+	branches      []perlLookBranch
+}
+
+type perlLookBranch struct {
+	insts []perlInst
+
+	// Длина альтернативы в рунах (для ретроспективной проверки)
+	length int
+}
+
+// perlProgram - скомпилированный шаблон. Регистры 0 и 1 хранят границы совпадения, следующие пары - границы групп,
+// остальные - позиции начала итераций циклов для защиты от бесконечного повторения пустого совпадения.
+type perlProgram struct {
+	insts []perlInst
+	slots int
+}
+
+// perlCompiler переводит дерево разбора в инструкции
+type perlCompiler struct {
+	insts []perlInst
+	slots int
+
+	// Количество захватывающих групп, чтобы проверять обратные ссылки
+	groups int
+}
+
+func compilePerl(node *perlNode, groups int) (*perlProgram, error) {
+	c := &perlCompiler{slots: 2 * (groups + 1), groups: groups}
+
+	c.emit(perlInst{op: opSave, x: 0})
+	if err := c.compile(node); err != nil {
+		return nil, err
+	}
+
+	c.emit(perlInst{op: opSave, x: 1})
+	c.emit(perlInst{op: opMatch})
+
+	return &perlProgram{insts: c.insts, slots: c.slots}, nil
+}
+
+func (c *perlCompiler) emit(inst perlInst) int {
+	c.insts = append(c.insts, inst)
+	return len(c.insts) - 1
+}
+
+func (c *perlCompiler) compile(node *perlNode) error {
+	switch node.kind {
+	case perlEmpty:
+	case perlLiteral:
+		r := node.r
+		if node.fold {
+			r = foldRune(r)
+		}
+
+		c.emit(perlInst{op: opRune, r: r, fold: node.fold})
+	case perlAny:
+		c.emit(perlInst{op: opAny})
+	case perlClass:
+		c.emit(perlInst{op: opClass, class: node.class, fold: node.fold})
+	case perlConcat:
+		for _, sub := range node.subs {
+			if err := c.compile(sub); err != nil {
+				return err
+			}
+		}
+	case perlAlternate:
+		// split L1, next; L1: первая альтернатива; jump end; next: split L2, ...
+		var jumps []int
+		for i, sub := range node.subs {
+			split := -1
+			if i < len(node.subs)-1 {
+				split = c.emit(perlInst{op: opSplit})
+				c.insts[split].x = len(c.insts)
+			}
+
+			if err := c.compile(sub); err != nil {
+				return err
+			}
+
+			if split >= 0 {
+				jumps = append(jumps, c.emit(perlInst{op: opJump}))
+				c.insts[split].y = len(c.insts)
+			}
+		}
+
+		for _, j := range jumps {
+			c.insts[j].x = len(c.insts)
+		}
+	case perlCapture:
+		c.emit(perlInst{op: opSave, x: 2 * node.index})
+		if err := c.compile(node.subs[0]); err != nil {
+			return err
+		}
+
+		c.emit(perlInst{op: opSave, x: 2*node.index + 1})
+	case perlRepeat:
+		return c.compileRepeat(node)
+	case perlBackref:
+		if node.index > c.groups {
+			return fmt.Errorf("reference to non-existent group %d", node.index)
+		}
+
+		c.emit(perlInst{op: opBackref, x: node.index, fold: node.fold})
+	case perlAssert:
+		c.emit(perlInst{op: opAssert, assert: node.assert})
+	case perlKeep:
+		c.emit(perlInst{op: opKeep})
+	case perlLook:
+		look, err := c.compileLook(node)
+		if err != nil {
+			return err
+		}
+
+		c.emit(perlInst{op: opLook, look: look})
+	}
+
+	return nil
+}
+
+// compileRepeat разворачивает повторение: min обязательных копий, затем max-min необязательных или цикл
+func (c *perlCompiler) compileRepeat(node *perlNode) error {
+	body := node.subs[0]
+
+	for i := 0; i < node.min; i++ {
+		if err := c.compile(body); err != nil {
+			return err
+		}
+	}
+
+	// Без ограничения сверху: L: split body, end; body; nullcheck; jump L
+	if node.max < 0 {
+		slot := c.slots
+		c.slots++
+
+		loop := c.emit(perlInst{op: opSplit})
+		c.emit(perlInst{op: opSave, x: slot})
+		if err := c.compile(body); err != nil {
+			return err
+		}
+
+		c.emit(perlInst{op: opNullCheck, x: slot})
+		c.emit(perlInst{op: opJump, x: loop})
+		c.setSplit(loop, loop+1, len(c.insts), node.greedy)
+		return nil
+	}
+
+	// Необязательные копии вложены друг в друга: (body(body(body)?)?)?
+	var splits []int
+	for i := node.min; i < node.max; i++ {
+		splits = append(splits, c.emit(perlInst{op: opSplit}))
+		if err := c.compile(body); err != nil {
+			return err
+		}
+	}
+
+	for _, split := range splits {
+		c.setSplit(split, split+1, len(c.insts), node.greedy)
+	}
+
+	return nil
+}
+
+// setSplit задаёт переходы для split с учётом жадности: жадное повторение сначала пробует тело
+func (c *perlCompiler) setSplit(split, body, next int, greedy bool) {
+	if greedy {
+		c.insts[split].x, c.insts[split].y = body, next
+	} else {
+		c.insts[split].x, c.insts[split].y = next, body
+	}
+}
+
+// compileLook компилирует тело проверки в отдельные программы
+func (c *perlCompiler) compileLook(node *perlNode) (*perlLookaround, error) {
+	look := &perlLookaround{ahead: node.ahead, negate: node.negate}
+
+	branches := []*perlNode{node.subs[0]}
+	if !node.ahead && node.subs[0].kind == perlAlternate {
+		branches = node.subs[0].subs
+	}
+
+	for _, branch := range branches {
+		length := 0
+		if !node.ahead {
+			var ok bool
+			if length, ok = fixedLength(branch); !ok {
+				return nil, fmt.Errorf("lookbehind assertion is not fixed length")
+			}
+		}
+
+		sub := &perlCompiler{slots: c.slots, groups: c.groups}
+		if err := sub.compile(branch); err != nil {
+			return nil, err
+		}
+
+		sub.emit(perlInst{op: opMatch})
+		c.slots = sub.slots
+
+		look.branches = append(look.branches, perlLookBranch{insts: sub.insts, length: length})
+	}
+
+	return look, nil
+}
+
+// fixedLength вычисляет длину совпадения в рунах, если она не зависит от строки
+func fixedLength(node *perlNode) (int, bool) {
+	switch node.kind {
+	case perlEmpty, perlAssert, perlLook:
+		return 0, true
+	case perlLiteral, perlAny, perlClass:
+		return 1, true
+	case perlConcat, perlCapture:
+		total := 0
+		for _, sub := range node.subs {
+			n, ok := fixedLength(sub)
+			if !ok {
+				return 0, false
+			}
+
+			total += n
+		}
+
+		return total, true
+	case perlAlternate:
+		length := -1
+		for _, sub := range node.subs {
+			n, ok := fixedLength(sub)
+			if !ok || (length >= 0 && n != length) {
+				return 0, false
+			}
+
+			length = n
+		}
+
+		return length, true
+	case perlRepeat:
+		n, ok := fixedLength(node.subs[0])
+		if !ok || node.min != node.max {
+			return 0, false
+		}
+
+		return n * node.min, true
+	default:
+		return 0, false
+	}
+}
+
+// perlMachine выполняет программы на одной строке, считая шаги
+type perlMachine struct {
+	text  string
+	steps int
+	limit int
+
+	// Бюджет шагов исчерпан
+	exceeded bool
+}
+
+// perlFrame - запись стека возвратов: альтернатива для продолжения (slot < 0) или восстановление регистра
+type perlFrame struct {
+	pc, pos   int
+	slot, old int
+}
+
+// run выполняет программу с позиции pos. В случае успеха возвращает позицию конца, а regs содержат регистры
+// успешного пути.
+func (m *perlMachine) run(insts []perlInst, pos int, regs []int) (int, bool) {
+	stack := []perlFrame{{pc: 0, pos: pos, slot: -1}}
+
+	for len(stack) > 0 {
+		frame := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if frame.slot >= 0 {
+			regs[frame.slot] = frame.old
+			continue
+		}
+
+		pc, pos := frame.pc, frame.pos
+
+	thread:
+		for {
+			m.steps++
+			if m.steps > m.limit {
+				m.exceeded = true
+				return 0, false
+			}
+
+			inst := &insts[pc]
+
+			switch inst.op {
+			case opRune:
+				r, size := utf8.DecodeRuneInString(m.text[pos:])
+				if size == 0 {
+					break thread
+				}
+
+				if inst.fold {
+					r = foldRune(r)
+				}
+
+				if r != inst.r {
+					break thread
+				}
+
+				pos += size
+				pc++
+
+			case opAny:
+				r, size := utf8.DecodeRuneInString(m.text[pos:])
+				if size == 0 || r == '\n' {
+					break thread
+				}
+
+				pos += size
+				pc++
+
+			case opClass:
+				r, size := utf8.DecodeRuneInString(m.text[pos:])
+				if size == 0 || !inst.class.matches(r, inst.fold) {
+					break thread
+				}
+
+				pos += size
+				pc++
+
+			case opSplit:
+				stack = append(stack, perlFrame{pc: inst.y, pos: pos, slot: -1})
+				pc = inst.x
+
+			case opJump:
+				pc = inst.x
+
+			case opSave, opKeep:
+				slot := inst.x
+				if inst.op == opKeep {
+					slot = 0
+				}
+
+				stack = append(stack, perlFrame{slot: slot, old: regs[slot]})
+				regs[slot] = pos
+				pc++
+
+			case opNullCheck:
+				// Итерация цикла не продвинулась: прекращаем повторение по этому пути
+				if regs[inst.x] == pos {
+					break thread
+				}
+
+				pc++
+
+			case opAssert:
+				if !m.assert(inst.assert, pos) {
+					break thread
+				}
+
+				pc++
+
+			case opBackref:
+				start, end := regs[2*inst.x], regs[2*inst.x+1]
+				if start < 0 || end < start {
+					break thread
+				}
+
+				n, ok := m.matchText(m.text[start:end], pos, inst.fold)
+				if !ok {
+					break thread
+				}
+
+				pos += n
+				pc++
+
+			case opLook:
+				matched, captured := m.look(inst.look, pos, regs)
+				if m.exceeded {
+					return 0, false
+				}
+
+				if matched == inst.look.negate {
+					break thread
+				}
+
+				// Группы, захваченные в положительной проверке, доступны дальше и восстанавливаются при возврате
+				for slot, value := range captured {
+					if regs[slot] != value {
+						stack = append(stack, perlFrame{slot: slot, old: regs[slot]})
+						regs[slot] = value
+					}
+				}
+
+				pc++
+
+			case opMatch:
+				return pos, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// look выполняет проверку в позиции pos. Для успешной положительной проверки возвращаются регистры, которые
+// она изменила.
+func (m *perlMachine) look(look *perlLookaround, pos int, regs []int) (bool, map[int]int) {
+	for _, branch := range look.branches {
+		start := pos
+		if !look.ahead {
+			for i := 0; i < branch.length; i++ {
+				_, size := utf8.DecodeLastRuneInString(m.text[:start])
+				if size == 0 {
+					start = -1
+					break
+				}
+
+				start -= size
+			}
+
+			if start < 0 {
+				continue
+			}
+		}
+
+		copied := append([]int(nil), regs...)
+		end, ok := m.run(branch.insts, start, copied)
+		if m.exceeded {
+			return false, nil
+		}
+
+		if !ok || (!look.ahead && end != pos) {
+			continue
+		}
+
+		if look.negate {
+			return true, nil
+		}
+
+		captured := map[int]int{}
+		for slot, value := range copied {
+			if regs[slot] != value {
+				captured[slot] = value
+			}
+		}
+
+		return true, captured
+	}
+
+	return false, nil
+}
+
+// assert проверяет условие в позиции pos
+func (m *perlMachine) assert(kind perlAssertKind, pos int) bool {
+	switch kind {
+	case assertLineStart:
+		return pos == 0
+	case assertLineEnd:
+		return pos == len(m.text)
+	}
+
+	before, after := false, false
+	if r, size := utf8.DecodeLastRuneInString(m.text[:pos]); size > 0 {
+		before = isWordRune(r)
+	}
+
+	if r, size := utf8.DecodeRuneInString(m.text[pos:]); size > 0 {
+		after = isWordRune(r)
+	}
+
+	if kind == assertWordBoundary {
+		return before != after
+	}
+
+	return before == after
+}
+
+// matchText проверяет, что с позиции pos в строке стоит text (для обратных ссылок), и возвращает его длину в строке
+func (m *perlMachine) matchText(text string, pos int, fold bool) (int, bool) {
+	if !fold {
+		if strings.HasPrefix(m.text[pos:], text) {
+			return len(text), true
+		}
+
+		return 0, false
+	}
+
+	n := pos
+	for _, want := range text {
+		r, size := utf8.DecodeRuneInString(m.text[n:])
+		if size == 0 || foldRune(r) != foldRune(want) {
+			return 0, false
+		}
+
+		n += size
+	}
+
+	return n - pos, true
+}
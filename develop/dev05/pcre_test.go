@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPerl_FindAll(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		fold     bool
+		text     string
+		expected []string
+	}{
+		{pattern: `(?<=user=)\w+`, text: "user=alice id=1 user=bob", expected: []string{"alice", "bob"}},
+		{pattern: `(\w)\1`, text: "aabbc xyzzy", expected: []string{"aa", "bb", "zz"}},
+		{pattern: `user=\K\w+`, text: "user=alice", expected: []string{"alice"}},
+		{pattern: `\b\w+(?=\.)`, text: "foo.bar baz.", expected: []string{"foo", "baz"}},
+		{pattern: `(?<!a)b+`, text: "abb cbb", expected: []string{"b", "bb"}},
+		{pattern: `(?<w>ab)c\k<w>`, text: "abcab abcac", expected: []string{"abcab"}},
+		{pattern: `a{2}b{1,2}`, text: "aabbb ab", expected: []string{"aabb"}},
+		{pattern: `z+?`, text: "zzz", expected: []string{"z", "z", "z"}},
+		{pattern: `(?i)лето`, text: "лето ЛЕТО", expected: []string{"лето", "ЛЕТО"}},
+		{pattern: `ЛЕТО`, fold: true, text: "Лето", expected: []string{"Лето"}},
+		{pattern: `(a)(?i:B)b`, text: "abb aBb aBB", expected: []string{"abb", "aBb"}},
+		{pattern: `a|ab`, text: "ab", expected: []string{"a"}},
+		{pattern: `(?<=ab|xyz)\w`, text: "abc xyzw", expected: []string{"c", "w"}},
+		{pattern: `[[:alpha:]]+=\d`, text: "id=1", expected: []string{"id=1"}},
+		{pattern: `\p{Cyrillic}+`, text: "abc где", expected: []string{"где"}},
+		{pattern: `[^\d\s]+`, text: "ab1 cd", expected: []string{"ab", "cd"}},
+		{pattern: `\x61\x{62}`, text: "ab", expected: []string{"ab"}},
+		{pattern: `(a*)*b`, text: "aab c", expected: []string{"aab"}},
+		{pattern: `(a|b)*c`, text: "x", expected: nil},
+		{pattern: `(?=(\w+))\1:`, text: "key: value", expected: []string{"key:"}},
+		{pattern: `^$`, text: "", expected: []string{""}},
+	}
+
+	for _, c := range tests {
+		perl, err := CompilePerl([]string{c.pattern}, c.fold, DefaultBacktrackLimit)
+		if err != nil {
+			t.Errorf("%s: %v", c.pattern, err)
+			continue
+		}
+
+		spans, err := perl.FindAll(c.text)
+		if err != nil {
+			t.Errorf("%s: %v", c.pattern, err)
+			continue
+		}
+
+		var actual []string
+		for _, span := range spans {
+			actual = append(actual, c.text[span.Start:span.End])
+		}
+
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s in %q: unexpected matches %q (expected %q)", c.pattern, c.text, actual, c.expected)
+		}
+	}
+}
+
+func TestCompilePerl_Errors(t *testing.T) {
+	patterns := []string{`(a`, `a)`, `*a`, `(?<=a+)b`, `\2(a)`, `a{2,1}`, `[z-a]`, `a++`, `\k<missing>`, `\q`}
+
+	for _, pattern := range patterns {
+		if _, err := CompilePerl([]string{pattern}, false, DefaultBacktrackLimit); err == nil {
+			t.Errorf("%s: expected compile error", pattern)
+		}
+	}
+}
+
+func TestPerl_BacktrackLimit(t *testing.T) {
+	perl, err := CompilePerl([]string{`(a+)+$`}, false, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = perl.FindAll(strings.Repeat("a", 40) + "!")
+
+	var limitErr *BacktrackLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected backtrack limit error, got %v", err)
+	}
+
+	// Строка, на которой исчерпан бюджет, считается неподходящей, а ошибка сообщается после обработки файла
+	predicate, err := CompilePatterns([]string{`(a+)+$`}, PatternOptions{Perl: true, BacktrackLimit: 100000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &strings.Builder{}
+	search := &Search{predicate: predicate}
+	input := strings.Repeat("a", 40) + "!\naaa\n"
+
+	_, err = (&Printer{}).Print(search, strings.NewReader(input), buf)
+	if !errors.As(err, &limitErr) {
+		t.Errorf("expected backtrack limit error, got %v", err)
+	}
+
+	if buf.String() != "aaa\n" {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestCompilePatterns_Perl(t *testing.T) {
+	tests := []struct {
+		options  PatternOptions
+		line     string
+		expected []Span
+	}{
+		{options: PatternOptions{Perl: true, WholeWord: true}, line: "foobar foo", expected: []Span{{Start: 7, End: 10}}},
+		{options: PatternOptions{Perl: true, WholeLine: true}, line: "foo bar", expected: nil},
+		{options: PatternOptions{Perl: true, IgnoreCase: true}, line: "FOO", expected: []Span{{Start: 0, End: 3}}},
+	}
+
+	for _, c := range tests {
+		predicate, err := CompilePatterns([]string{"foo"}, c.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := predicate(c.line); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%+v in %q: unexpected spans %v (expected %v)", c.options, c.line, actual, c.expected)
+		}
+	}
+
+	if _, err := CompilePatterns([]string{"foo"}, PatternOptions{Perl: true, Fixed: true}); err == nil {
+		t.Errorf("expected error for -F with -P")
+	}
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 )
 
 /*
@@ -35,55 +38,177 @@ var (
 	count      = flag.Bool("c", false, "print only count")
 	ignoreCase = flag.Bool("i", false, "ignore case")
 	invert     = flag.Bool("v", false, "invert predicate")
-	fixed      = flag.Bool("F", false, "only exact matches")
+	fixed      = flag.Bool("F", false, "interpret patterns as fixed strings")
 	lineNumber = flag.Bool("n", false, "print line number")
+
+	recursive    = flag.Bool("r", false, "search directories recursively")
+	include      = listFlag("include", "search only files whose base name matches the glob")
+	exclude      = listFlag("exclude", "skip files whose base name matches the glob")
+	excludeDir   = listFlag("exclude-dir", "skip directories whose base name matches the glob")
+	withFileName = flag.Bool("H", false, "print file name for each match")
+	noFileName   = flag.Bool("h", false, "never print file names")
+	filesWith    = flag.Bool("l", false, "print only names of files with matches")
+	filesWithout = flag.Bool("L", false, "print only names of files without matches")
+	workers      = flag.Int("workers", runtime.GOMAXPROCS(0), "number of files searched concurrently")
+
+	onlyMatching = flag.Bool("o", false, "print only matched parts of lines")
+	byteOffset   = flag.Bool("b", false, "print byte offset of each line")
+	color        = colorFlag("color", "highlight matches: never, always or auto")
+
+	patternArgs  = listFlag("e", "pattern to search for (may be repeated)")
+	patternFiles = listFlag("f", "read patterns from file, one per line (may be repeated)")
+	wholeLine    = flag.Bool("x", false, "match whole lines only")
+	wholeWord    = flag.Bool("w", false, "match whole words only")
+
+	groupSeparator   = flag.String("group-separator", "--", "separator between groups of context lines")
+	noGroupSeparator = flag.Bool("no-group-separator", false, "do not print separators between groups")
+
+	maxCount = flag.Int("m", -1, "stop reading a file after NUM selected lines")
+	quiet    = flag.Bool("q", false, "print nothing, exit with zero status on first match")
+	silent   = flag.Bool("s", false, "suppress error messages about unreadable files")
+
+	perl           = flag.Bool("P", false, "interpret patterns as Perl-compatible regular expressions")
+	backtrackLimit = flag.Int("backtrack-limit", DefaultBacktrackLimit, "maximum steps per line for -P")
+
+	decompress = flag.Bool("z", false, "decompress gzip, bzip2 and zstd inputs detected by magic bytes")
+
+	jsonOutput = flag.Bool("json", false, "print results as JSON lines: begin, match, context, end and summary records")
+
+	expression = flag.String("expr", "", "boolean query over patterns, e.g. 'error && !timeout && (db || cache)'")
+
+	follow = flag.Bool("follow", false, "keep reading files as they grow, like tail -f, handling truncation and rotation")
+
+	binaryAsText = flag.Bool("a", false, "process binary files as text")
+	binaryFiles  = binaryFilesFlag("binary-files", "how to handle binary files: binary, without-match or text")
 )
 
-// LinePredicate - тип для функции, которая принимает строку
-type LinePredicate func(string) bool
+// colorFlag регистрирует флаг --color
+func colorFlag(name, usage string) *colorMode {
+	m := colorNever
+	flag.Var(&m, name, usage)
+	return &m
+}
 
-// Search отвечает за выбор строк, соответствующих условиям поиска
-type Search struct {
-	// Содержит строки, среди которых мы буем производить поиск
-	sourceLines []string
+// stdinName - имя, под которым выводится стандартный ввод
+const stdinName = "(standard input)"
+
+// Коды возврата, как в GNU grep
+const (
+	// Найдена хотя бы одна строка
+	exitMatch = 0
+
+	// Ни одной строки не найдено
+	exitNoMatch = 1
 
-	// Заполняется методом Find и содержит сопоставление "номер строки => подходит ли под условия"
-	matches map[int]bool
+	// Произошла ошибка
+	exitError = 2
+)
+
+// errNoPattern сообщает, что шаблон поиска не задан
+var errNoPattern = errors.New("no pattern provided")
+
+// errExpressionConflict сообщает, что --expr задан вместе с -e или -f
+var errExpressionConflict = errors.New("--expr cannot be combined with -e or -f")
+
+// errFollowConflict сообщает, что --follow задан вместе с флагами, которые выводят результат только в конце файла
+var errFollowConflict = errors.New("--follow cannot be combined with -r, -c or -L")
+
+// errJSONConflict сообщает, что --json задан вместе с флагами, которые выводят не строки
+var errJSONConflict = errors.New("--json cannot be combined with -c, -l or -L")
+
+// Span - найденный фрагмент строки: смещения его начала и конца в байтах
+type Span struct {
+	Start int
+	End   int
+}
+
+// LinePredicate - тип для функции, которая принимает строку и возвращает найденные в ней фрагменты. Если строка не
+// соответствует условию, функция возвращает nil; пустой, но не nil результат означает совпадение без фрагментов.
+type LinePredicate func(string) []Span
+
+// Search отвечает за проверку строк на соответствие условиям поиска
+type Search struct {
+	// Функция, проверяющая строку
+	predicate LinePredicate
 
 	// Нужно ли инвертировать результаты поиска?
 	invert bool
 
-	// Заполняется методом Find и хранит количество строк, соответствующих условиям поиска
+	// Количество проверенных строк, соответствующих условиям поиска
 	count int
+
+	// Первая ошибка проверки строки (например, исчерпан бюджет шагов -P)
+	err error
+
+	// Статистика поиска для --json
+	stats Stats
 }
 
-func NewSearch(lines []string) *Search {
+func NewSearch(predicate LinePredicate) *Search {
 	return &Search{
-		sourceLines: lines,
-		matches:     make(map[int]bool),
-		invert:      *invert,
+		predicate: predicate,
+		invert:    *invert,
 	}
 }
 
-// Find осуществляет поиск среди сохранённых в структуре строк, используя функцию predicate.
-func (s *Search) Find(predicate LinePredicate) {
-	for i, line := range s.sourceLines {
-		// Проверяем, соответствует ли строка
-		r := predicate(line)
+// Match проверяет, соответствует ли строка условиям поиска, и учитывает её в счётчике найденных строк. Кроме
+// результата проверки возвращаются найденные в строке фрагменты без учёта инвертирования: при -v они нужны, чтобы
+// подсветить совпадения в строках контекста.
+func (s *Search) Match(line string) (selected bool, spans []Span) {
+	// Проверяем, соответствует ли строка
+	spans = s.check(line)
+	r := spans != nil
+
+	// Если нужно, инвертируем результат
+	if s.invert {
+		r = !r
+	}
+
+	// Инкремент количества найденных строк и фрагментов в них
+	if r {
+		s.count++
 
-		// Если нужно, инвертируем результат
-		if s.invert {
-			r = !r
+		if !s.invert {
+			s.stats.Matches += len(spans)
 		}
+	}
 
-		// Сохраняем результат
-		s.matches[i] = r
+	return r, spans
+}
+
+// check вызывает predicate. Если движок -P исчерпал бюджет шагов, строка считается неподходящей, как в GNU grep,
+// а ошибка запоминается и сообщается после обработки файла.
+func (s *Search) check(line string) (spans []Span) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(*BacktrackLimitError)
+			if !ok {
+				panic(r)
+			}
+
+			if s.err == nil {
+				s.err = err
+			}
 
-		// Инкремент количества найденных строк
-		if r {
-			s.count++
+			spans = nil
 		}
-	}
+	}()
+
+	return s.predicate(line)
+}
+
+// sourceLine - строка входных данных вместе с её номером, смещением в байтах и найденными фрагментами
+type sourceLine struct {
+	number int
+	offset int64
+	text   string
+	spans  []Span
+
+	// Перевод строки, которым заканчивалась строка во входных данных ("\n", "\r\n" или пустой в конце данных)
+	eol string
+
+	// Выбрана ли строка условиями поиска (иначе это строка контекста)
+	selected bool
 }
 
 // Printer отвечает за вывод результата поиска
@@ -99,6 +224,54 @@ type Printer struct {
 
 	// Вывести только количество строк?
 	onlyCount bool
+
+	// Имя просматриваемого файла
+	fileName string
+
+	// Выводить имя файла перед каждой строкой?
+	withFileName bool
+
+	// Вывести только имя файла, если в нём есть подходящие строки?
+	listMatching bool
+
+	// Вывести только имя файла, если в нём нет подходящих строк?
+	listNonMatching bool
+
+	// Выводить только найденные фрагменты, каждый на отдельной строке?
+	onlyMatching bool
+
+	// Выводить смещение строки (или фрагмента при -o) в байтах?
+	byteOffsets bool
+
+	// Цвета для раскраски вывода; nil, если раскраска выключена
+	colors *Colors
+
+	// Выполняется ли поиск с -v (нужно для выбора цветов)
+	invert bool
+
+	// Выводить разделитель между несмежными группами строк?
+	groupSeparators bool
+
+	// Разделитель между группами строк
+	groupSeparator string
+
+	// Ограничено ли количество выбранных строк в файле (-m)?
+	limitCount bool
+
+	// Сколько выбранных строк искать в файле, если количество ограничено
+	maxCount int
+
+	// Ничего не выводить и прекратить чтение после первой выбранной строки?
+	quiet bool
+
+	// Распаковывать сжатые входные данные?
+	decompress bool
+
+	// Выводить результаты в формате JSON?
+	json bool
+
+	// Как поступать с двоичными файлами
+	binaryFiles binaryMode
 }
 
 func NewPrinter() *Printer {
@@ -107,173 +280,600 @@ func NewPrinter() *Printer {
 		*after = *context
 	}
 
+	// Как в GNU grep, разделители групп выводятся, только если явно задан хотя бы один из флагов контекста
+	contextRequested := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "A" || f.Name == "B" || f.Name == "C" {
+			contextRequested = true
+		}
+	})
+
 	return &Printer{
 		linesBefore: *before,
 		linesAfter:  *after,
 		lineNumbers: *lineNumber,
 		onlyCount:   *count,
+
+		listMatching:    *filesWith,
+		listNonMatching: *filesWithout,
+
+		onlyMatching: *onlyMatching,
+		byteOffsets:  *byteOffset,
+		invert:       *invert,
+
+		groupSeparators: contextRequested && !*noGroupSeparator,
+		groupSeparator:  *groupSeparator,
+
+		limitCount: *maxCount >= 0,
+		maxCount:   *maxCount,
+		quiet:      *quiet,
+
+		decompress: *decompress,
+		json:       *jsonOutput,
+
+		binaryFiles: binaryFilesMode(),
 	}
 }
 
-func (p *Printer) Print(search *Search, writer io.Writer) (n int, err error) {
-	// Если нужно вывести только количество, то выводим и сразу выходим
-	if p.onlyCount {
-		return fmt.Fprintf(writer, "%d\n", search.count)
+// binaryFilesMode возвращает режим обработки двоичных файлов: -a равносилен --binary-files=text
+func binaryFilesMode() binaryMode {
+	if *binaryAsText {
+		return binaryText
+	}
+
+	return *binaryFiles
+}
+
+// ForFile возвращает копию Printer для вывода результатов поиска в файле name
+func (p *Printer) ForFile(name string) *Printer {
+	c := *p
+	c.fileName = name
+	return &c
+}
+
+// Print построчно читает данные из reader, проверяет каждую строку с помощью search и выводит в writer подходящие
+// строки вместе с контекстом. Входные данные не загружаются в память целиком: хранятся только последние linesBefore
+// строк, которые могут понадобиться как контекст перед следующей найденной строкой.
+func (p *Printer) Print(search *Search, reader io.Reader, writer io.Writer) (n int, err error) {
+	// Сжатые данные распаковываются на лету, поэтому номера строк и смещения относятся к распакованному тексту
+	if p.decompress {
+		if reader, err = Decompress(reader); err != nil {
+			return 0, err
+		}
 	}
 
-	// Флаг, который отвечает за то, что нужно выводить строку
-	shouldPrint := false
+	scanner := newLineReader(reader)
 
-	// Переменная для индекса последней найденной строки
-	lastMatch := -1
+	// Двоичный файл определяется по первому блоку, пока из него не прочитано ни одной строки
+	binaryOffset := int64(-1)
+	if p.binaryFiles != binaryText {
+		binaryOffset = scanner.BinaryOffset()
+	}
+
+	binary := binaryOffset >= 0
+
+	// Решает, какие строки выводить как контекст и где начинаются новые группы
+	window := newContextWindow(p.linesBefore, p.linesAfter)
+	groups := 0
+
+	// Для --json: время начала поиска и выведена ли уже запись begin
+	started := time.Now()
+	begun := false
 
-	for i, line := range search.sourceLines {
-		// Эту строки (и, возможно, последующие строки) выводим при одном из условий:
-		// - после обработки предыдущей строки shouldPrint имеет значение true
-		// - текущая строка соответствует условиям поиска
-		// - строка на позиции i + linesBefore соответствует условиям поиска
-		currentMatches := search.matches[i]
-		hasMatchFurther := i+p.linesBefore < len(search.sourceLines) && search.matches[i+p.linesBefore]
-		shouldPrint = shouldPrint || currentMatches || hasMatchFurther
+	// С -m 0 ни одна строка не выбирается, и файл можно не читать. С --binary-files=without-match в двоичном файле
+	// тоже ничего не ищется.
+	if p.limitCount && p.maxCount == 0 || binary && p.binaryFiles == binaryWithoutMatch {
+		window.Stop()
+	}
+
+	var offset int64
+	for number := 1; !window.Done() && scanner.Scan(); number++ {
+		line := sourceLine{number: number, offset: offset, text: scanner.Text(), eol: scanner.EOL()}
+		offset += int64(scanner.Size())
+
+		// После остановки по -m строки уже не проверяются, а только дополняют контекст
+		if !window.Stopped() {
+			line.selected, line.spans = search.Match(line.text)
+		}
 
-		// Если текущая строка соответствует условиям поиска, сохраняем её индекс
-		if currentMatches {
-			lastMatch = i
+		// Для -q достаточно первой найденной строки, выводить ничего не нужно
+		if line.selected && p.quiet {
+			return n, nil
 		}
 
-		if shouldPrint {
-			// Выводим номер строки, если нужно
-			if p.lineNumbers {
-				// Как в оригинальном grep: если текущая строка соответствует условиям, выводим ":", иначе - "-"
-				matchIndicator := "-"
-				if currentMatches {
-					matchIndicator = ":"
-				}
-
-				// Записываем номер строки и индикатор соответствия условиям поиска
-				m, err := fmt.Fprintf(writer, "%d%s", i+1, matchIndicator)
-				n += m
-				if err != nil {
-					return n, err
-				}
+		// Для -l достаточно первой найденной строки: выводим имя файла и прекращаем чтение
+		if line.selected && p.listMatching {
+			return p.printFileName(writer)
+		}
+
+		// Строки двоичного файла не выводятся: достаточно сообщить, что в нём есть совпадение. С -c и -L строки
+		// по-прежнему нужно посчитать.
+		if line.selected && binary && !p.onlyCount && !p.listNonMatching {
+			return p.printBinaryMatch(writer, search, n, offset, started, binaryOffset)
+		}
+
+		// Найдено максимальное количество строк: после этой строки поиск останавливается, дочитывается только
+		// контекст после неё
+		stop := line.selected && p.limitCount && search.count == p.maxCount
+
+		// Если нужно вывести только количество или список файлов, то сами строки не выводим
+		if p.onlyCount || p.listMatching || p.listNonMatching || binary {
+			if stop {
+				window.Stop()
 			}
 
-			// Выводим саму строку
-			m, err := writer.Write([]byte(line + "\n"))
+			continue
+		}
+
+		lines, newGroup := window.Next(line)
+
+		// Перед каждой новой группой, кроме первой, выводим разделитель. В JSON группы видны по номерам строк.
+		if newGroup && p.groupSeparators && !p.json {
+			m, err := p.printSeparator(writer, groups == 0)
+			n += m
+			if err != nil {
+				return n, err
+			}
+
+			groups++
+		}
+
+		// В JSON перед первой выводимой строкой файла выводится запись begin
+		if p.json && !begun && len(lines) > 0 {
+			m, err := p.printJSONBegin(writer)
+			n += m
+			if err != nil {
+				return n, err
+			}
+
+			begun = true
+		}
+
+		for _, l := range lines {
+			m, err := p.printLine(writer, l)
 			n += m
 			if err != nil {
 				return n, err
 			}
 		}
 
-		// Если текущая строка является последней из тех, которые нужно вывести после найденной, то отключаем вывод для
-		// последующих строк.
-		if lastMatch+p.linesAfter <= i {
-			shouldPrint = false
+		if stop {
+			window.Stop()
 		}
 	}
 
+	if err := scanner.Err(); err != nil {
+		return n, err
+	}
+
+	// Ошибки проверки строк не прерывают поиск, но сообщаются после вывода результатов файла
+	defer func() {
+		if err == nil {
+			err = search.err
+		}
+	}()
+
+	if p.quiet {
+		return
+	}
+
+	if p.json {
+		return p.finishJSON(writer, search, n, offset, started, begun, nil)
+	}
+
+	// Для -L имя файла выводится, только если подходящих строк не нашлось
+	if p.listMatching || p.listNonMatching {
+		if p.listNonMatching && search.count == 0 {
+			return p.printFileName(writer)
+		}
+
+		return
+	}
+
+	// Если нужно вывести только количество, то выводим его после обработки всех строк
+	if p.onlyCount {
+		if p.withFileName {
+			m, err := fmt.Fprintf(writer, "%s%d\n", p.fileNamePrefix(":"), search.count)
+			return n + m, err
+		}
+
+		m, err := fmt.Fprintf(writer, "%d\n", search.count)
+		return n + m, err
+	}
+
 	return
 }
 
-// ReadInput получает входные данные либо из файла, если задано его название, либо из stdin.
-func ReadInput() []string {
-	// Используем stdin по умолчанию
-	input := os.Stdin
+// finishJSON собирает статистику поиска в файле и, если для файла выводились строки, выводит запись end
+func (p *Printer) finishJSON(
+	writer io.Writer,
+	search *Search,
+	n int,
+	searched int64,
+	started time.Time,
+	begun bool,
+	binaryOffset *int64,
+) (int, error) {
+	search.stats.Searches = 1
+	search.stats.BytesSearched = searched
+	search.stats.BytesPrinted = int64(n)
+	search.stats.MatchedLines = search.count
+	search.stats.Elapsed = jsonDuration(time.Since(started))
+
+	if search.count > 0 {
+		search.stats.SearchesWithMatch = 1
+	}
+
+	if !begun {
+		return n, nil
+	}
 
-	// Если указано название файла, то открываем его и используем в качестве ввода
-	if fileName := flag.Arg(1); fileName != "" {
-		file, err := os.Open(fileName)
+	m, err := p.printJSONEnd(writer, search.stats, binaryOffset)
+	return n + m, err
+}
+
+// printBinaryMatch сообщает, что в двоичном файле найдена подходящая строка, и завершает поиск в файле. В JSON
+// вместо сообщения выводятся записи begin и end со смещением первого байта NUL.
+func (p *Printer) printBinaryMatch(
+	writer io.Writer,
+	search *Search,
+	n int,
+	searched int64,
+	started time.Time,
+	binaryOffset int64,
+) (int, error) {
+	if p.json {
+		m, err := p.printJSONBegin(writer)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(3)
-			return nil
+			return n + m, err
 		}
 
-		input = file
+		return p.finishJSON(writer, search, n+m, searched, started, true, &binaryOffset)
+	}
+
+	m, err := fmt.Fprintf(writer, "Binary file %s matches\n", p.fileName)
+	return n + m, err
+}
+
+// printSeparator выводит разделитель групп. Перед первой группой файла разделитель нужен, только если до этого
+// что-то выводилось для других файлов: это может решить лишь writer, общий для всех файлов.
+func (p *Printer) printSeparator(writer io.Writer, first bool) (int, error) {
+	separator := p.colors.paint(p.colors.separator(), p.groupSeparator) + "\n"
+
+	if !first {
+		return io.WriteString(writer, separator)
 	}
 
-	return ReadLines(input)
+	if w, ok := writer.(separatorWriter); ok {
+		return w.WriteSeparator([]byte(separator))
+	}
+
+	return 0, nil
+}
+
+// printFileName выводит имя файла на отдельной строке (для -l и -L)
+func (p *Printer) printFileName(writer io.Writer) (int, error) {
+	return io.WriteString(writer, p.colors.paint(p.colors.fileName(), p.fileName)+"\n")
 }
 
-// ReadLines извлекает список строк из reader
-func ReadLines(reader io.ReadCloser) []string {
-	defer reader.Close()
+// fileNamePrefix возвращает имя файла вместе с разделителем separator
+func (p *Printer) fileNamePrefix(separator string) string {
+	return p.colors.paint(p.colors.fileName(), p.fileName) + p.colors.paint(p.colors.separator(), separator)
+}
+
+// linePrefix возвращает начало выводимой строки: имя файла, номер строки и смещение offset, если их нужно выводить.
+// Как в оригинальном grep, после выбранной строки ставится ":", после строки контекста - "-".
+func (p *Printer) linePrefix(line sourceLine, offset int64, selected bool) string {
+	separator := "-"
+	if selected {
+		separator = ":"
+	}
 
-	scanner := bufio.NewScanner(reader)
-	lines := make([]string, 0)
+	prefix := &strings.Builder{}
 
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	if p.withFileName {
+		prefix.WriteString(p.fileNamePrefix(separator))
 	}
 
-	return lines
+	if p.lineNumbers {
+		prefix.WriteString(p.colors.paint(p.colors.lineNumber(), strconv.Itoa(line.number)))
+		prefix.WriteString(p.colors.paint(p.colors.separator(), separator))
+	}
+
+	if p.byteOffsets {
+		prefix.WriteString(p.colors.paint(p.colors.byteOffset(), strconv.FormatInt(offset, 10)))
+		prefix.WriteString(p.colors.paint(p.colors.separator(), separator))
+	}
+
+	return prefix.String()
 }
 
-// MakePredicate создаёт функцию для проверки строк, исходя из параметров программы.
-func MakePredicate(pattern string) (LinePredicate, error) {
+// printLine выводит строку line, при необходимости предваряя её именем файла, номером и смещением
+func (p *Printer) printLine(writer io.Writer, line sourceLine) (n int, err error) {
+	if p.json {
+		return p.printJSONLine(writer, line)
+	}
+
+	// При -o выводим каждый непустой найденный фрагмент на отдельной строке, а строки контекста пропускаем
+	if p.onlyMatching {
+		if !line.selected {
+			return 0, nil
+		}
+
+		out := &strings.Builder{}
+		for _, span := range line.spans {
+			if span.Start == span.End {
+				continue
+			}
+
+			out.WriteString(p.linePrefix(line, line.offset+int64(span.Start), true))
+			out.WriteString(p.colors.paint(p.colors.matchColor(true), line.text[span.Start:span.End]))
+			out.WriteString("\n")
+		}
+
+		return io.WriteString(writer, out.String())
+	}
+
+	out := &strings.Builder{}
+	out.WriteString(p.linePrefix(line, line.offset, line.selected))
+	out.WriteString(p.highlight(line))
+	out.WriteString("\n")
+
+	return io.WriteString(writer, out.String())
+}
+
+// highlight раскрашивает текст строки так же, как GNU grep: найденные фрагменты выделяются цветом ms или mc, остальной
+// текст - цветом всей строки sl или cx. Фрагменты подсвечиваются только в строках, которые соответствуют шаблону,
+// то есть в выбранных строках без -v и в строках контекста с -v.
+func (p *Printer) highlight(line sourceLine) string {
+	if p.colors == nil {
+		return line.text
+	}
+
+	lineColor := p.colors.lineColor(line.selected, p.invert)
+	matchColor := p.colors.matchColor(line.selected)
+
+	out := &strings.Builder{}
+	rest := 0
+
+	if line.selected != p.invert && matchColor != "" {
+		for _, span := range line.spans {
+			if span.Start == span.End || span.Start < rest {
+				continue
+			}
+
+			if lineColor != "" {
+				out.WriteString(p.colors.start(lineColor))
+			}
+
+			out.WriteString(line.text[rest:span.Start])
+			out.WriteString(p.colors.paint(matchColor, line.text[span.Start:span.End]))
+			rest = span.End
+		}
+	}
+
+	if rest < len(line.text) {
+		out.WriteString(p.colors.paint(lineColor, line.text[rest:]))
+	}
+
+	return out.String()
+}
+
+// OpenInput открывает входные данные: файл с путём path, либо stdin, если путь равен "-".
+func OpenInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	return os.Open(path)
+}
+
+// InputPaths возвращает список просматриваемых путей: все аргументы, кроме шаблона. Если аргументов нет, то при -r
+// возвращается пустой список (текущий каталог), иначе просматривается stdin.
+func InputPaths(args []string) []string {
+	if len(args) > 0 || *recursive {
+		return args
+	}
+
+	return []string{"-"}
+}
+
+// ShowFileNames решает, нужно ли выводить имена файлов: по умолчанию они выводятся, если просматривается больше
+// одного файла или каталог рекурсивно. Флаги -H и -h явно включают и выключают вывод.
+func ShowFileNames(paths []string) bool {
 	switch {
-	case *fixed && *ignoreCase:
-		// Строгое соответствие всей строки, но без учёта регистра
-		return func(s string) bool {
-			return strings.ToLower(s) == strings.ToLower(pattern)
-		}, nil
-
-	case *fixed && !*ignoreCase:
-		// Строгое соответствие всей строки с учётом регистра
-		return func(s string) bool {
-			return s == pattern
-		}, nil
+	case *noFileName:
+		return false
+	case *withFileName:
+		return true
+	case len(paths) > 1:
+		return true
+	case !*recursive:
+		return false
+	case len(paths) == 0:
+		return true
+	}
 
-	default:
-		// Соответствие части строки шаблону
+	info, err := os.Stat(paths[0])
+	return err == nil && info.IsDir()
+}
+
+// MakePredicate создаёт функцию для проверки строк по любому из шаблонов, исходя из параметров программы.
+func MakePredicate(patterns ...string) (LinePredicate, error) {
+	return CompilePatterns(patterns, patternOptions())
+}
 
-		// Если нужно игнорировать регистр, то добавляем (?i) в начало шаблона
-		if *ignoreCase {
-			pattern = "(?i)" + pattern
+// MakeExpressionPredicate создаёт функцию для проверки строк по булеву выражению над шаблонами (--expr), исходя из
+// параметров программы.
+func MakeExpressionPredicate(expression string) (LinePredicate, error) {
+	return CompileExpression(expression, patternOptions())
+}
+
+// patternOptions возвращает параметры сопоставления строк с шаблонами, заданные флагами
+func patternOptions() PatternOptions {
+	return PatternOptions{
+		Fixed:      *fixed,
+		IgnoreCase: *ignoreCase,
+		WholeLine:  *wholeLine,
+		WholeWord:  *wholeWord,
+
+		Perl:           *perl,
+		BacktrackLimit: *backtrackLimit,
+	}
+}
+
+// Patterns возвращает шаблоны поиска и оставшиеся аргументы. Если заданы -e или -f, шаблоны берутся из них, а все
+// аргументы считаются файлами; иначе шаблоном считается первый аргумент. С --expr шаблоны задаёт выражение, и все
+// аргументы - файлы.
+func Patterns() (patterns []string, args []string, err error) {
+	if *expression != "" {
+		if len(*patternArgs) != 0 || len(*patternFiles) != 0 {
+			return nil, nil, errExpressionConflict
 		}
 
-		p, err := regexp.Compile(pattern)
+		return nil, flag.Args(), nil
+	}
+
+	if len(*patternArgs) == 0 && len(*patternFiles) == 0 {
+		if flag.NArg() == 0 {
+			return nil, nil, errNoPattern
+		}
+
+		return SplitPatterns(flag.Args()[:1]), flag.Args()[1:], nil
+	}
+
+	patterns = SplitPatterns(*patternArgs)
+	for _, path := range *patternFiles {
+		filePatterns, err := ReadPatterns(path)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		return func(s string) bool {
-			return p.MatchString(s)
-		}, nil
+		patterns = append(patterns, filePatterns...)
+	}
+
+	return patterns, flag.Args(), nil
+}
+
+// ExitStatus выбирает код возврата, как GNU grep: 0, если найдена хотя бы одна строка, 1, если не найдено ни одной,
+// и 2, если произошла ошибка. С -q найденная строка важнее ошибок: скрипты проверяют только наличие совпадения.
+func ExitStatus(matched, failed, quiet bool) int {
+	switch {
+	case matched && quiet:
+		return exitMatch
+	case failed:
+		return exitError
+	case matched:
+		return exitMatch
+	default:
+		return exitNoMatch
 	}
 }
 
 func main() {
+	started := time.Now()
 	flag.Parse()
 
-	// Считаем первый аргумент шаблоном, по которому будем искать
-	pattern := flag.Arg(0)
-	if pattern == "" {
-		_, _ = fmt.Fprintln(os.Stderr, "no pattern provided")
-		os.Exit(1)
+	// В JSON выводятся только строки, поэтому флаги, заменяющие их количеством или именами файлов, не поддерживаются
+	if *jsonOutput && (*count || *filesWith || *filesWithout) {
+		_, _ = fmt.Fprintln(os.Stderr, errJSONConflict)
+		os.Exit(exitError)
+		return
+	}
+
+	// При слежении за файлами они не кончаются, поэтому результат, который выводится в конце файла, не появится никогда
+	if *follow && (*recursive || *count || *filesWithout) {
+		_, _ = fmt.Fprintln(os.Stderr, errFollowConflict)
+		os.Exit(exitError)
 		return
 	}
 
-	// Составляем функцию для проверки строки
-	predicate, err := MakePredicate(pattern)
+	// Получаем шаблоны из -e и -f либо из первого аргумента
+	patterns, args, err := Patterns()
 	if err != nil {
-		_, _ = fmt.Fprintln(os.Stderr, "invalid pattern:", err)
-		os.Exit(2)
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
 		return
 	}
 
-	// Считываем входные данные и осуществляем поиск по ним
-	lines := ReadInput()
-	search := NewSearch(lines)
-	search.Find(predicate)
+	// Составляем функцию для проверки строки: по выражению, если оно задано, иначе по любому из шаблонов
+	var predicate LinePredicate
+	if *expression != "" {
+		predicate, err = MakeExpressionPredicate(*expression)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "invalid expression:", err)
+			os.Exit(exitError)
+			return
+		}
+	} else {
+		predicate, err = MakePredicate(patterns...)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "invalid pattern:", err)
+			os.Exit(exitError)
+			return
+		}
+	}
+
+	// Просматриваем файлы параллельно, выводя результат каждого файла целиком и в порядке обнаружения файлов
+	paths := InputPaths(args)
+	filter := &FileFilter{Include: *include, Exclude: *exclude, ExcludeDir: *excludeDir}
 
-	// Выводим результат
 	printer := NewPrinter()
-	_, err = printer.Print(search, os.Stdout)
+	printer.withFileName = ShowFileNames(paths)
+
+	if color.Enabled(os.Stdout) {
+		printer.colors = ParseColors(DefaultColors(), os.Getenv("GREP_COLORS"))
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	matched, failed := false, false
+	stats := Stats{}
+
+	prepare := func(path string) (*Search, *Printer) {
+		name := path
+		if path == "-" {
+			name = stdinName
+		}
+
+		return NewSearch(predicate), printer.ForFile(name)
+	}
+
+	finish := func(search *Search) bool {
+		matched = matched || search.count > 0
+		stats.Add(search.stats)
+
+		// С -q после первой найденной строки остальные файлы можно не просматривать
+		return matched && *quiet
+	}
+
+	report := func(err error) {
+		// Ошибки чтения отдельных файлов не прерывают поиск, но отражаются в коде возврата
+		failed = true
+		if !*silent {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	// При слежении строки выводятся сразу, без буферизации, иначе они появлялись бы с задержкой
+	if *follow {
+		FollowFiles(paths, DefaultFollowInterval, os.Stdout, prepare, finish, report)
+	} else {
+		err = SearchFiles(paths, *recursive, filter, *workers, out, prepare, finish, report)
+	}
+
+	// Итоговая запись выводится, даже если ничего не нашлось, но не с -q
+	if err == nil && *jsonOutput && !*quiet {
+		err = PrintJSONSummary(out, time.Since(started), stats)
+	}
+
+	if err == nil {
+		err = out.Flush()
+	}
+
 	if err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
-		os.Exit(4)
-		return
+		failed = true
 	}
+
+	os.Exit(ExitStatus(matched, failed, *quiet))
 }
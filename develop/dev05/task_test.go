@@ -3,86 +3,96 @@ package main
 import (
 	"bytes"
 	"reflect"
+	"strings"
 	"testing"
 )
 
+// predicateOf превращает проверку строки в LinePredicate, для которой найденным фрагментом считается вся строка
+func predicateOf(f func(string) bool) LinePredicate {
+	return func(s string) []Span {
+		if !f(s) {
+			return nil
+		}
+
+		return []Span{{Start: 0, End: len(s)}}
+	}
+}
+
 type searchTestCase struct {
-	search    *Search
-	predicate func(string) bool
-	expected  map[int]bool
+	search        *Search
+	lines         []string
+	expected      []bool
+	expectedCount int
 }
 
-func TestSearch_Find(t *testing.T) {
+func TestSearch_Match(t *testing.T) {
+	isOne := predicateOf(func(s string) bool {
+		return s == "1"
+	})
+
 	tests := []searchTestCase{
 		{
 			search: &Search{
-				sourceLines: []string{"2", "2", "1", "1", "2", "2"},
-				matches:     make(map[int]bool),
-				invert:      false,
-			},
-			predicate: func(s string) bool {
-				return s == "1"
-			},
-			expected: map[int]bool{
-				0: false, 1: false,
-				2: true, 3: true,
-				4: false, 5: false,
+				predicate: isOne,
+				invert:    false,
 			},
+			lines:         []string{"2", "2", "1", "1", "2", "2"},
+			expected:      []bool{false, false, true, true, false, false},
+			expectedCount: 2,
 		},
 		{
 			search: &Search{
-				sourceLines: []string{"2", "2", "1", "1", "2", "2"},
-				matches:     make(map[int]bool),
-				invert:      true,
-			},
-			predicate: func(s string) bool {
-				return s == "1"
-			},
-			expected: map[int]bool{
-				0: true, 1: true,
-				2: false, 3: false,
-				4: true, 5: true,
+				predicate: isOne,
+				invert:    true,
 			},
+			lines:         []string{"2", "2", "1", "1", "2", "2"},
+			expected:      []bool{true, true, false, false, true, true},
+			expectedCount: 4,
 		},
 	}
 
 	for _, c := range tests {
-		c.search.Find(c.predicate)
-		if !reflect.DeepEqual(c.search.matches, c.expected) {
-			t.Errorf("unexpected result: %v (expected %v)", c.search.matches, c.expected)
+		actual := make([]bool, len(c.lines))
+		for i, line := range c.lines {
+			actual[i], _ = c.search.Match(line)
+		}
+
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("unexpected result: %v (expected %v)", actual, c.expected)
+		}
+
+		if c.search.count != c.expectedCount {
+			t.Errorf("unexpected count: %d (expected %d)", c.search.count, c.expectedCount)
 		}
 	}
 }
 
 type printerTestCase struct {
-	search   *Search
-	printer  *Printer
-	expected string
+	input     string
+	predicate LinePredicate
+	printer   *Printer
+	expected  string
 }
 
 func TestPrinter_Print(t *testing.T) {
+	isOne := predicateOf(func(s string) bool {
+		return s == "1"
+	})
+
+	always := predicateOf(func(string) bool {
+		return true
+	})
+
 	tests := []printerTestCase{
 		{
-			search: &Search{
-				sourceLines: []string{"2", "2", "1", "1", "2", "2"},
-				matches: map[int]bool{
-					0: false, 1: false,
-					2: true, 3: true,
-					4: false, 5: false,
-				},
-			},
-			printer:  &Printer{},
-			expected: "1\n1\n",
+			input:     "2\n2\n1\n1\n2\n2\n",
+			predicate: isOne,
+			printer:   &Printer{},
+			expected:  "1\n1\n",
 		},
 		{
-			search: &Search{
-				sourceLines: []string{"2", "2", "1", "1", "2", "2"},
-				matches: map[int]bool{
-					0: false, 1: false,
-					2: true, 3: true,
-					4: false, 5: false,
-				},
-			},
+			input:     "2\n2\n1\n1\n2\n2\n",
+			predicate: isOne,
 			printer: &Printer{
 				linesBefore: 1,
 				linesAfter:  1,
@@ -90,14 +100,8 @@ func TestPrinter_Print(t *testing.T) {
 			expected: "2\n1\n1\n2\n",
 		},
 		{
-			search: &Search{
-				sourceLines: []string{"2", "2", "1", "1", "2", "2"},
-				matches: map[int]bool{
-					0: false, 1: false,
-					2: true, 3: true,
-					4: false, 5: false,
-				},
-			},
+			input:     "2\n2\n1\n1\n2\n2\n",
+			predicate: isOne,
 			printer: &Printer{
 				linesBefore: 0,
 				linesAfter:  1,
@@ -105,14 +109,8 @@ func TestPrinter_Print(t *testing.T) {
 			expected: "1\n1\n2\n",
 		},
 		{
-			search: &Search{
-				sourceLines: []string{"2", "2", "1", "1", "2", "2"},
-				matches: map[int]bool{
-					0: false, 1: false,
-					2: true, 3: true,
-					4: false, 5: false,
-				},
-			},
+			input:     "2\n2\n1\n1\n2\n2\n",
+			predicate: isOne,
 			printer: &Printer{
 				linesBefore: 1,
 				linesAfter:  0,
@@ -120,28 +118,141 @@ func TestPrinter_Print(t *testing.T) {
 			expected: "2\n1\n1\n",
 		},
 		{
-			search: &Search{
-				sourceLines: []string{"2", "2", "1", "1", "2", "2"},
-				matches: map[int]bool{
-					0: true, 1: true,
-					2: true, 3: true,
-					4: true, 5: true,
-				},
-			},
+			input:     "2\n2\n1\n1\n2\n2\n",
+			predicate: always,
 			printer: &Printer{
 				linesBefore: 2,
 				linesAfter:  2,
 			},
 			expected: "2\n2\n1\n1\n2\n2\n",
 		},
+		{
+			input:     "2\n2\n1\n1\n2\n2\n",
+			predicate: isOne,
+			printer: &Printer{
+				linesBefore: 5,
+				linesAfter:  5,
+				lineNumbers: true,
+			},
+			expected: "1-2\n2-2\n3:1\n4:1\n5-2\n6-2\n",
+		},
+		{
+			input:     "2\n2\n1\n1\n2\n2\n",
+			predicate: isOne,
+			printer: &Printer{
+				onlyCount: true,
+			},
+			expected: "2\n",
+		},
+		{
+			input:     "foo boo\nbar\nzoo\n",
+			predicate: mustPredicate(t, "[fbz]oo"),
+			printer: &Printer{
+				onlyMatching: true,
+				lineNumbers:  true,
+				byteOffsets:  true,
+				linesBefore:  1,
+			},
+			expected: "1:0:foo\n1:4:boo\n3:12:zoo\n",
+		},
+		{
+			input:     "foo\r\nbar\nbaz\n",
+			predicate: mustPredicate(t, "z"),
+			printer: &Printer{
+				byteOffsets: true,
+				linesBefore: 1,
+			},
+			expected: "5-bar\n9:baz\n",
+		},
+		{
+			// После -m строк выводится контекст, даже если он сам соответствует условиям
+			input:     "2\n1\n1\n2\n1\n",
+			predicate: isOne,
+			printer: &Printer{
+				linesAfter:  2,
+				lineNumbers: true,
+				limitCount:  true,
+				maxCount:    1,
+			},
+			expected: "2:1\n3-1\n4-2\n",
+		},
+		{
+			input:     "1\n1\n1\n",
+			predicate: isOne,
+			printer: &Printer{
+				onlyCount:  true,
+				limitCount: true,
+				maxCount:   2,
+			},
+			expected: "2\n",
+		},
+		{
+			input:     "1\n",
+			predicate: isOne,
+			printer: &Printer{
+				limitCount: true,
+				maxCount:   0,
+			},
+			expected: "",
+		},
+		{
+			input:     "2\n1\n",
+			predicate: isOne,
+			printer: &Printer{
+				quiet:     true,
+				onlyCount: true,
+			},
+			expected: "",
+		},
 	}
 
 	for _, c := range tests {
 		buf := &bytes.Buffer{}
-		_, _ = c.printer.Print(c.search, buf)
+		_, _ = c.printer.Print(&Search{predicate: c.predicate}, strings.NewReader(c.input), buf)
 
 		if buf.String() != c.expected {
 			t.Errorf("unexpected result: %s (expected %s)", buf.String(), c.expected)
 		}
 	}
 }
+
+// mustPredicate составляет LinePredicate для регулярного выражения pattern
+func mustPredicate(t *testing.T, pattern string) LinePredicate {
+	predicate, err := MakePredicate(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return predicate
+}
+
+func TestMakePredicate_Spans(t *testing.T) {
+	spans := mustPredicate(t, "o+")("foo boo bar")
+	if !reflect.DeepEqual(spans, []Span{{Start: 1, End: 3}, {Start: 5, End: 7}}) {
+		t.Errorf("unexpected spans: %v", spans)
+	}
+
+	if spans := mustPredicate(t, "o+")("bar"); spans != nil {
+		t.Errorf("unexpected spans for non-matching line: %v", spans)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		matched, failed, quiet bool
+		expected               int
+	}{
+		{matched: true, expected: exitMatch},
+		{expected: exitNoMatch},
+		{failed: true, expected: exitError},
+		{matched: true, failed: true, expected: exitError},
+		{matched: true, failed: true, quiet: true, expected: exitMatch},
+		{failed: true, quiet: true, expected: exitError},
+	}
+
+	for _, c := range tests {
+		if actual := ExitStatus(c.matched, c.failed, c.quiet); actual != c.expected {
+			t.Errorf("ExitStatus(%v, %v, %v) = %d (expected %d)", c.matched, c.failed, c.quiet, actual, c.expected)
+		}
+	}
+}
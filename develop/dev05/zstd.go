@@ -0,0 +1,1166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// Декодер формата Zstandard (RFC 8878). Поддерживаются все виды блоков и кодирования, кроме словарей.
+
+const (
+	zstdMagic = 0xFD2FB528
+
+	// Пропускаемые кадры имеют магические числа 0x184D2A50-0x184D2A5F
+	zstdSkippableMask  = 0xFFFFFFF0
+	zstdSkippableMagic = 0x184D2A50
+
+	// Наибольший размер блока после распаковки
+	zstdMaxBlockSize = 128 << 10
+
+	// Наибольший поддерживаемый размер окна: больше нам не нужно, а память надо ограничивать
+	zstdMaxWindowSize = 1 << 30
+)
+
+var (
+	errZstdCorrupted  = errors.New("zstd: corrupted input")
+	errZstdDictionary = errors.New("zstd: dictionaries are not supported")
+	errZstdChecksum   = errors.New("zstd: checksum mismatch")
+)
+
+// ZstdReader распаковывает поток Zstandard. Данные распаковываются по блокам, в памяти хранится только окно, на
+// которое могут ссылаться следующие блоки.
+type ZstdReader struct {
+	reader *bufio.Reader
+
+	// Распакованные данные текущего кадра, которые ещё могут понадобиться; pending - ещё не прочитанная их часть
+	history []byte
+	pending []byte
+
+	// Находимся ли внутри кадра и параметры текущего кадра
+	inFrame    bool
+	windowSize int
+	checksum   bool
+	digest     *xxhash64
+
+	// Состояние, которое переходит от блока к блоку внутри кадра
+	offsets   [3]int
+	huffman   *huffmanTable
+	llTable   *fseTable
+	ofTable   *fseTable
+	mlTable   *fseTable
+	literals  []byte
+	sequences []zstdSequence
+
+	err error
+}
+
+func NewZstdReader(reader io.Reader) *ZstdReader {
+	return &ZstdReader{reader: bufio.NewReader(reader)}
+}
+
+func (z *ZstdReader) Read(p []byte) (int, error) {
+	for len(z.pending) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+
+		z.err = z.next()
+	}
+
+	n := copy(p, z.pending)
+	z.pending = z.pending[n:]
+	return n, nil
+}
+
+// next распаковывает следующий блок, при необходимости читая заголовок кадра
+func (z *ZstdReader) next() error {
+	if !z.inFrame {
+		return z.readFrameHeader()
+	}
+
+	var header [3]byte
+	if _, err := io.ReadFull(z.reader, header[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	value := uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16
+	last := value&1 == 1
+	blockType := (value >> 1) & 3
+	size := int(value >> 3)
+
+	// Окно не должно расти бесконечно: оставляем только то, на что могут ссылаться следующие блоки
+	if len(z.history) > 2*z.windowSize+zstdMaxBlockSize {
+		keep := z.history[len(z.history)-z.windowSize:]
+		z.history = append(z.history[:0], keep...)
+	}
+
+	start := len(z.history)
+
+	switch blockType {
+	case 0:
+		// Несжатый блок
+		if size > zstdMaxBlockSize {
+			return errZstdCorrupted
+		}
+
+		z.history = append(z.history, make([]byte, size)...)
+		if _, err := io.ReadFull(z.reader, z.history[start:]); err != nil {
+			return unexpectedEOF(err)
+		}
+	case 1:
+		// Блок из одного повторяющегося байта
+		if size > zstdMaxBlockSize {
+			return errZstdCorrupted
+		}
+
+		b, err := z.reader.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		for i := 0; i < size; i++ {
+			z.history = append(z.history, b)
+		}
+	case 2:
+		if size > zstdMaxBlockSize {
+			return errZstdCorrupted
+		}
+
+		block := make([]byte, size)
+		if _, err := io.ReadFull(z.reader, block); err != nil {
+			return unexpectedEOF(err)
+		}
+
+		if err := z.decodeBlock(block); err != nil {
+			return err
+		}
+	default:
+		return errZstdCorrupted
+	}
+
+	z.pending = z.history[start:]
+	if z.digest != nil {
+		z.digest.Write(z.pending)
+	}
+
+	if last {
+		z.inFrame = false
+
+		if z.checksum {
+			var sum [4]byte
+			if _, err := io.ReadFull(z.reader, sum[:]); err != nil {
+				return unexpectedEOF(err)
+			}
+
+			if binary.LittleEndian.Uint32(sum[:]) != uint32(z.digest.Sum64()) {
+				return errZstdChecksum
+			}
+		}
+	}
+
+	return nil
+}
+
+// readFrameHeader читает заголовок следующего кадра, пропуская пропускаемые кадры. В конце потока возвращает io.EOF.
+func (z *ZstdReader) readFrameHeader() error {
+	var magic [4]byte
+	if _, err := io.ReadFull(z.reader, magic[:]); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+
+		return unexpectedEOF(err)
+	}
+
+	switch m := binary.LittleEndian.Uint32(magic[:]); {
+	case m&zstdSkippableMask == zstdSkippableMagic:
+		var size [4]byte
+		if _, err := io.ReadFull(z.reader, size[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+
+		_, err := z.reader.Discard(int(binary.LittleEndian.Uint32(size[:])))
+		return unexpectedEOF(err)
+	case m != zstdMagic:
+		return errors.New("zstd: invalid magic number")
+	}
+
+	descriptor, err := z.reader.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	contentSizeFlag := descriptor >> 6
+	singleSegment := descriptor&(1<<5) != 0
+	z.checksum = descriptor&(1<<2) != 0
+	dictionaryFlag := descriptor & 3
+
+	if descriptor&(1<<3) != 0 {
+		return errZstdCorrupted
+	}
+
+	windowSize := 0
+	if !singleSegment {
+		b, err := z.reader.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		windowLog := 10 + int(b>>3)
+		if windowLog > 30 {
+			return errors.New("zstd: window size is too large")
+		}
+
+		windowBase := 1 << windowLog
+		windowSize = windowBase + (windowBase/8)*int(b&7)
+	}
+
+	if dictionaryFlag != 0 {
+		return errZstdDictionary
+	}
+
+	contentSizeBytes := [4]int{0, 2, 4, 8}[contentSizeFlag]
+	if contentSizeFlag == 0 && singleSegment {
+		contentSizeBytes = 1
+	}
+
+	var field [8]byte
+	if _, err := io.ReadFull(z.reader, field[:contentSizeBytes]); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	contentSize := binary.LittleEndian.Uint64(field[:])
+	if contentSizeBytes == 2 {
+		contentSize += 256
+	}
+
+	if singleSegment {
+		if contentSize > zstdMaxWindowSize {
+			return errors.New("zstd: window size is too large")
+		}
+
+		windowSize = int(contentSize)
+	}
+
+	z.inFrame = true
+	z.windowSize = windowSize
+	z.history = z.history[:0]
+	z.offsets = [3]int{1, 4, 8}
+	z.huffman, z.llTable, z.ofTable, z.mlTable = nil, nil, nil, nil
+
+	z.digest = nil
+	if z.checksum {
+		z.digest = newXXHash64()
+	}
+
+	return nil
+}
+
+// unexpectedEOF превращает io.EOF внутри кадра в io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// decodeBlock распаковывает сжатый блок и дописывает результат в history
+func (z *ZstdReader) decodeBlock(block []byte) error {
+	n, err := z.decodeLiterals(block)
+	if err != nil {
+		return err
+	}
+
+	if err := z.decodeSequences(block[n:]); err != nil {
+		return err
+	}
+
+	return z.execute()
+}
+
+// decodeLiterals распаковывает раздел литералов и возвращает его размер
+func (z *ZstdReader) decodeLiterals(block []byte) (int, error) {
+	if len(block) == 0 {
+		return 0, errZstdCorrupted
+	}
+
+	literalsType := block[0] & 3
+	sizeFormat := (block[0] >> 2) & 3
+
+	// Несжатые литералы и литералы из одного повторяющегося байта
+	if literalsType < 2 {
+		var size, headerSize int
+
+		switch sizeFormat {
+		case 0, 2:
+			size, headerSize = int(block[0]>>3), 1
+		case 1:
+			if len(block) < 2 {
+				return 0, errZstdCorrupted
+			}
+
+			size, headerSize = int(block[0]>>4)|int(block[1])<<4, 2
+		case 3:
+			if len(block) < 3 {
+				return 0, errZstdCorrupted
+			}
+
+			size, headerSize = int(block[0]>>4)|int(block[1])<<4|int(block[2])<<12, 3
+		}
+
+		if size > zstdMaxBlockSize {
+			return 0, errZstdCorrupted
+		}
+
+		if literalsType == 0 {
+			if len(block) < headerSize+size {
+				return 0, errZstdCorrupted
+			}
+
+			z.literals = append(z.literals[:0], block[headerSize:headerSize+size]...)
+			return headerSize + size, nil
+		}
+
+		if len(block) < headerSize+1 {
+			return 0, errZstdCorrupted
+		}
+
+		z.literals = z.literals[:0]
+		for i := 0; i < size; i++ {
+			z.literals = append(z.literals, block[headerSize])
+		}
+
+		return headerSize + 1, nil
+	}
+
+	// Литералы, сжатые кодом Хаффмана
+	headerSize, sizeBits, streams := 3, 10, 4
+	switch sizeFormat {
+	case 0:
+		streams = 1
+	case 2:
+		headerSize, sizeBits = 4, 14
+	case 3:
+		headerSize, sizeBits = 5, 18
+	}
+
+	if len(block) < headerSize {
+		return 0, errZstdCorrupted
+	}
+
+	var header uint64
+	for i := headerSize - 1; i >= 0; i-- {
+		header = header<<8 | uint64(block[i])
+	}
+
+	mask := uint64(1)<<sizeBits - 1
+	regenerated := int((header >> 4) & mask)
+	compressed := int((header >> (4 + sizeBits)) & mask)
+
+	if regenerated > zstdMaxBlockSize || len(block) < headerSize+compressed {
+		return 0, errZstdCorrupted
+	}
+
+	data := block[headerSize : headerSize+compressed]
+
+	if literalsType == 2 {
+		table, n, err := readHuffmanTable(data)
+		if err != nil {
+			return 0, err
+		}
+
+		z.huffman = table
+		data = data[n:]
+	} else if z.huffman == nil {
+		return 0, errZstdCorrupted
+	}
+
+	z.literals = z.literals[:0]
+	if streams == 1 {
+		if err := z.huffman.decode(data, regenerated, &z.literals); err != nil {
+			return 0, err
+		}
+
+		return headerSize + compressed, nil
+	}
+
+	// Четыре потока: сначала таблица с размерами первых трёх
+	if len(data) < 6 {
+		return 0, errZstdCorrupted
+	}
+
+	sizes := [4]int{
+		int(binary.LittleEndian.Uint16(data[0:])),
+		int(binary.LittleEndian.Uint16(data[2:])),
+		int(binary.LittleEndian.Uint16(data[4:])),
+	}
+
+	sizes[3] = len(data) - 6 - sizes[0] - sizes[1] - sizes[2]
+	if sizes[3] < 0 {
+		return 0, errZstdCorrupted
+	}
+
+	data = data[6:]
+	segment := (regenerated + 3) / 4
+
+	for i, size := range sizes {
+		count := segment
+		if i == 3 {
+			count = regenerated - 3*segment
+		}
+
+		if count < 0 {
+			return 0, errZstdCorrupted
+		}
+
+		if err := z.huffman.decode(data[:size], count, &z.literals); err != nil {
+			return 0, err
+		}
+
+		data = data[size:]
+	}
+
+	return headerSize + compressed, nil
+}
+
+// zstdSequence - команда восстановления данных: скопировать literals литералов, затем match байт с расстояния offset
+type zstdSequence struct {
+	literals, match, offset int
+}
+
+// Режимы кодирования кодов последовательностей
+const (
+	modePredefined = iota
+	modeRLE
+	modeCompressed
+	modeRepeat
+)
+
+// decodeSequences читает раздел последовательностей
+func (z *ZstdReader) decodeSequences(data []byte) error {
+	z.sequences = z.sequences[:0]
+
+	if len(data) == 0 {
+		return errZstdCorrupted
+	}
+
+	count := int(data[0])
+	switch {
+	case count == 0:
+		return nil
+	case count < 128:
+		data = data[1:]
+	case count < 255:
+		if len(data) < 2 {
+			return errZstdCorrupted
+		}
+
+		count = (count-128)<<8 | int(data[1])
+		data = data[2:]
+	default:
+		if len(data) < 3 {
+			return errZstdCorrupted
+		}
+
+		count = int(data[1]) | int(data[2])<<8 + 0x7F00
+		data = data[3:]
+	}
+
+	if len(data) == 0 {
+		return errZstdCorrupted
+	}
+
+	modes := data[0]
+	data = data[1:]
+
+	var err error
+	var n int
+
+	if z.llTable, n, err = readSequenceTable(data, int(modes>>6), z.llTable, &literalsLengthCodes); err != nil {
+		return err
+	}
+
+	data = data[n:]
+
+	if z.ofTable, n, err = readSequenceTable(data, int(modes>>4)&3, z.ofTable, &offsetCodes); err != nil {
+		return err
+	}
+
+	data = data[n:]
+
+	if z.mlTable, n, err = readSequenceTable(data, int(modes>>2)&3, z.mlTable, &matchLengthCodes); err != nil {
+		return err
+	}
+
+	data = data[n:]
+
+	stream, err := newReverseBits(data)
+	if err != nil {
+		return err
+	}
+
+	ll := z.llTable.init(stream)
+	of := z.ofTable.init(stream)
+	ml := z.mlTable.init(stream)
+
+	for i := 0; i < count; i++ {
+		ofCode := z.ofTable.entries[of].symbol
+		mlCode := z.mlTable.entries[ml].symbol
+		llCode := z.llTable.entries[ll].symbol
+
+		if int(ofCode) > 31 || int(mlCode) >= len(matchLengthBase) || int(llCode) >= len(literalsLengthBase) {
+			return errZstdCorrupted
+		}
+
+		// Дополнительные биты читаются в порядке: смещение, длина совпадения, количество литералов
+		offsetValue := 1<<ofCode + int(stream.read(int(ofCode)))
+		match := matchLengthBase[mlCode] + int(stream.read(int(matchLengthBits[mlCode])))
+		literals := literalsLengthBase[llCode] + int(stream.read(int(literalsLengthBits[llCode])))
+
+		z.sequences = append(z.sequences, zstdSequence{
+			literals: literals,
+			match:    match,
+			offset:   z.resolveOffset(offsetValue, literals),
+		})
+
+		// Состояния обновляются в порядке: литералы, длина совпадения, смещение
+		if i < count-1 {
+			ll = z.llTable.update(ll, stream)
+			ml = z.mlTable.update(ml, stream)
+			of = z.ofTable.update(of, stream)
+		}
+
+		if stream.overflow() {
+			return errZstdCorrupted
+		}
+	}
+
+	return nil
+}
+
+// resolveOffset вычисляет смещение с учётом повторяемых смещений
+func (z *ZstdReader) resolveOffset(value, literals int) int {
+	if value > 3 {
+		offset := value - 3
+		z.offsets = [3]int{offset, z.offsets[0], z.offsets[1]}
+		return offset
+	}
+
+	// Без литералов повторяемые смещения сдвигаются на одно
+	index := value
+	if literals == 0 {
+		index++
+	}
+
+	switch index {
+	case 1:
+		return z.offsets[0]
+	case 2:
+		offset := z.offsets[1]
+		z.offsets[0], z.offsets[1] = offset, z.offsets[0]
+		return offset
+	case 3:
+		offset := z.offsets[2]
+		z.offsets = [3]int{offset, z.offsets[0], z.offsets[1]}
+		return offset
+	default:
+		offset := z.offsets[0] - 1
+		z.offsets = [3]int{offset, z.offsets[0], z.offsets[1]}
+		return offset
+	}
+}
+
+// execute выполняет последовательности, дописывая результат в history
+func (z *ZstdReader) execute() error {
+	literals := z.literals
+	start := len(z.history)
+
+	for _, s := range z.sequences {
+		if s.literals > len(literals) {
+			return errZstdCorrupted
+		}
+
+		z.history = append(z.history, literals[:s.literals]...)
+		literals = literals[s.literals:]
+
+		from := len(z.history) - s.offset
+		if s.offset <= 0 || from < 0 {
+			return errZstdCorrupted
+		}
+
+		// Совпадение может перекрываться с копируемыми данными, поэтому копируем побайтно
+		for i := 0; i < s.match; i++ {
+			z.history = append(z.history, z.history[from+i])
+		}
+	}
+
+	z.history = append(z.history, literals...)
+
+	if len(z.history)-start > zstdMaxBlockSize {
+		return errZstdCorrupted
+	}
+
+	return nil
+}
+
+// reverseBits читает биты потока, записанного от конца к началу. Последний байт потока содержит маркер - старший
+// единичный бит, перед которым начинаются данные.
+type reverseBits struct {
+	data []byte
+
+	// Количество ещё не прочитанных битов; отрицательное, если прочитано больше, чем было
+	pos int
+}
+
+func newReverseBits(data []byte) (*reverseBits, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errZstdCorrupted
+	}
+
+	last := data[len(data)-1]
+	return &reverseBits{data: data, pos: 8*(len(data)-1) + bits.Len8(last) - 1}, nil
+}
+
+// read читает n битов (не больше 56). Биты за началом потока считаются нулевыми.
+func (b *reverseBits) read(n int) uint64 {
+	v := b.peek(n)
+	b.pos -= n
+	return v
+}
+
+// peek возвращает следующие n битов, не продвигаясь по потоку
+func (b *reverseBits) peek(n int) uint64 {
+	if n == 0 {
+		return 0
+	}
+
+	start := b.pos - n
+	shift := 0
+	if start < 0 {
+		shift = -start
+		n -= shift
+		start = 0
+	}
+
+	if n <= 0 {
+		return 0
+	}
+
+	var buf [8]byte
+	copy(buf[:], b.data[start>>3:])
+
+	v := binary.LittleEndian.Uint64(buf[:]) >> (start & 7)
+	v &= 1<<n - 1
+	return v << shift
+}
+
+// overflow сообщает, что прочитано больше битов, чем было в потоке
+func (b *reverseBits) overflow() bool {
+	return b.pos < 0
+}
+
+// fseEntry - состояние таблицы FSE: символ и способ перейти в следующее состояние
+type fseEntry struct {
+	symbol   uint8
+	bits     uint8
+	newState uint16
+}
+
+// fseTable - таблица декодирования FSE (tANS)
+type fseTable struct {
+	accuracyLog int
+	entries     []fseEntry
+}
+
+// init читает начальное состояние
+func (t *fseTable) init(stream *reverseBits) int {
+	return int(stream.read(t.accuracyLog))
+}
+
+// update переходит в следующее состояние
+func (t *fseTable) update(state int, stream *reverseBits) int {
+	e := t.entries[state]
+	return int(e.newState) + int(stream.read(int(e.bits)))
+}
+
+// buildFSETable строит таблицу по нормированным вероятностям символов (-1 означает "меньше единицы")
+func buildFSETable(norm []int, accuracyLog int) (*fseTable, error) {
+	size := 1 << accuracyLog
+	t := &fseTable{accuracyLog: accuracyLog, entries: make([]fseEntry, size)}
+
+	next := make([]int, len(norm))
+	high := size - 1
+
+	for s, count := range norm {
+		if count == -1 {
+			t.entries[high].symbol = uint8(s)
+			high--
+			next[s] = 1
+		} else {
+			next[s] = count
+		}
+	}
+
+	step := size>>1 + size>>3 + 3
+	position := 0
+	for s, count := range norm {
+		for i := 0; i < count; i++ {
+			t.entries[position].symbol = uint8(s)
+
+			position = (position + step) & (size - 1)
+			for position > high {
+				position = (position + step) & (size - 1)
+			}
+		}
+	}
+
+	if position != 0 {
+		return nil, errZstdCorrupted
+	}
+
+	for i := range t.entries {
+		s := t.entries[i].symbol
+		state := next[s]
+		next[s]++
+
+		nbBits := accuracyLog - (bits.Len(uint(state)) - 1)
+		t.entries[i].bits = uint8(nbBits)
+		t.entries[i].newState = uint16(state<<nbBits - size)
+	}
+
+	return t, nil
+}
+
+// readFSETable читает описание таблицы FSE и возвращает таблицу и количество прочитанных байтов
+func readFSETable(data []byte, maxSymbol, maxAccuracyLog int) (*fseTable, int, error) {
+	stream := &forwardBits{data: data}
+
+	accuracyLog := int(stream.read(4)) + 5
+	if accuracyLog > maxAccuracyLog {
+		return nil, 0, errZstdCorrupted
+	}
+
+	remaining := 1<<accuracyLog + 1
+	threshold := 1 << accuracyLog
+	nbBits := accuracyLog + 1
+
+	var norm []int
+	for remaining > 1 {
+		if len(norm) > maxSymbol {
+			return nil, 0, errZstdCorrupted
+		}
+
+		max := 2*threshold - 1 - remaining
+
+		var count int
+		if low := int(stream.peek(nbBits - 1)); low < max {
+			count = low
+			stream.skip(nbBits - 1)
+		} else {
+			count = int(stream.peek(nbBits)) & (2*threshold - 1)
+			if count >= threshold {
+				count -= max
+			}
+
+			stream.skip(nbBits)
+		}
+
+		count--
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+
+		norm = append(norm, count)
+
+		// После нулевой вероятности следуют счётчики повторений нулей по 2 бита
+		if count == 0 {
+			for {
+				repeat := int(stream.read(2))
+				for i := 0; i < repeat; i++ {
+					norm = append(norm, 0)
+				}
+
+				if repeat != 3 {
+					break
+				}
+			}
+		}
+
+		for remaining < threshold && threshold > 1 {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+
+	if remaining != 1 || len(norm) > maxSymbol+1 || stream.pos > 8*len(data) {
+		return nil, 0, errZstdCorrupted
+	}
+
+	table, err := buildFSETable(norm, accuracyLog)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return table, (stream.pos + 7) / 8, nil
+}
+
+// forwardBits читает биты от младших к старшим, начиная с первого байта
+type forwardBits struct {
+	data []byte
+	pos  int
+}
+
+func (b *forwardBits) peek(n int) uint64 {
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		bit := b.pos + i
+		v <<= 1
+		if bit>>3 < len(b.data) {
+			v |= uint64(b.data[bit>>3]>>(bit&7)) & 1
+		}
+	}
+
+	return v
+}
+
+func (b *forwardBits) skip(n int) {
+	b.pos += n
+}
+
+func (b *forwardBits) read(n int) uint64 {
+	v := b.peek(n)
+	b.skip(n)
+	return v
+}
+
+// sequenceCodes описывает один из трёх видов кодов последовательностей
+type sequenceCodes struct {
+	maxSymbol      int
+	maxAccuracyLog int
+	predefined     *fseTable
+}
+
+var (
+	literalsLengthCodes = sequenceCodes{maxSymbol: 35, maxAccuracyLog: 9, predefined: mustFSETable([]int{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}, 6)}
+
+	matchLengthCodes = sequenceCodes{maxSymbol: 52, maxAccuracyLog: 9, predefined: mustFSETable([]int{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1, -1, -1,
+	}, 6)}
+
+	offsetCodes = sequenceCodes{maxSymbol: 31, maxAccuracyLog: 8, predefined: mustFSETable([]int{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}, 5)}
+
+	literalsLengthBase = []int{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+
+	literalsLengthBits = []uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+
+	matchLengthBase = []int{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+
+	matchLengthBits = []uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+func mustFSETable(norm []int, accuracyLog int) *fseTable {
+	table, err := buildFSETable(norm, accuracyLog)
+	if err != nil {
+		panic(err)
+	}
+
+	return table
+}
+
+// readSequenceTable читает таблицу для кодов последовательностей в режиме mode
+func readSequenceTable(data []byte, mode int, previous *fseTable, codes *sequenceCodes) (*fseTable, int, error) {
+	switch mode {
+	case modePredefined:
+		return codes.predefined, 0, nil
+	case modeRLE:
+		if len(data) == 0 || int(data[0]) > codes.maxSymbol {
+			return nil, 0, errZstdCorrupted
+		}
+
+		return &fseTable{entries: []fseEntry{{symbol: data[0]}}}, 1, nil
+	case modeCompressed:
+		return readFSETable(data, codes.maxSymbol, codes.maxAccuracyLog)
+	default:
+		if previous == nil {
+			return nil, 0, errZstdCorrupted
+		}
+
+		return previous, 0, nil
+	}
+}
+
+// huffmanEntry - элемент таблицы декодирования Хаффмана
+type huffmanEntry struct {
+	symbol uint8
+	bits   uint8
+}
+
+// huffmanTable - таблица декодирования Хаффмана, индексируемая следующими maxBits битами потока
+type huffmanTable struct {
+	maxBits int
+	entries []huffmanEntry
+}
+
+// readHuffmanTable читает описание дерева Хаффмана и возвращает таблицу и количество прочитанных байтов
+func readHuffmanTable(data []byte) (*huffmanTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errZstdCorrupted
+	}
+
+	header := int(data[0])
+	var weights []int
+	var size int
+
+	if header >= 128 {
+		// Веса записаны напрямую, по 4 бита
+		count := header - 127
+		size = 1 + (count+1)/2
+		if len(data) < size {
+			return nil, 0, errZstdCorrupted
+		}
+
+		for i := 0; i < count; i++ {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				weights = append(weights, int(b>>4))
+			} else {
+				weights = append(weights, int(b&15))
+			}
+		}
+	} else {
+		// Веса сжаты FSE и декодируются двумя чередующимися состояниями
+		size = 1 + header
+		if len(data) < size {
+			return nil, 0, errZstdCorrupted
+		}
+
+		table, n, err := readFSETable(data[1:size], 255, 6)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		stream, err := newReverseBits(data[1+n : size])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		state1 := table.init(stream)
+		state2 := table.init(stream)
+
+		for len(weights) < 255 {
+			weights = append(weights, int(table.entries[state1].symbol))
+			state1 = table.update(state1, stream)
+
+			if stream.overflow() {
+				weights = append(weights, int(table.entries[state2].symbol))
+				break
+			}
+
+			weights = append(weights, int(table.entries[state2].symbol))
+			state2 = table.update(state2, stream)
+
+			if stream.overflow() {
+				weights = append(weights, int(table.entries[state1].symbol))
+				break
+			}
+		}
+	}
+
+	// Вес последнего символа не записывается: он дополняет сумму до степени двойки
+	total := 0
+	for _, w := range weights {
+		if w > 11 {
+			return nil, 0, errZstdCorrupted
+		}
+
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+
+	if total == 0 {
+		return nil, 0, errZstdCorrupted
+	}
+
+	maxBits := bits.Len(uint(total))
+	rest := 1<<maxBits - total
+	if rest&(rest-1) != 0 || maxBits > 11 {
+		return nil, 0, errZstdCorrupted
+	}
+
+	weights = append(weights, bits.Len(uint(rest)))
+
+	// Символы с меньшим весом получают более длинные коды и занимают меньше элементов таблицы
+	t := &huffmanTable{maxBits: maxBits, entries: make([]huffmanEntry, 1<<maxBits)}
+	position := 0
+	for w := 1; w <= maxBits; w++ {
+		for symbol, weight := range weights {
+			if weight != w {
+				continue
+			}
+
+			n := 1 << (w - 1)
+			for i := 0; i < n; i++ {
+				t.entries[position+i] = huffmanEntry{symbol: uint8(symbol), bits: uint8(maxBits + 1 - w)}
+			}
+
+			position += n
+		}
+	}
+
+	return t, size, nil
+}
+
+// decode распаковывает count символов из потока data и дописывает их в out
+func (t *huffmanTable) decode(data []byte, count int, out *[]byte) error {
+	stream, err := newReverseBits(data)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		e := t.entries[stream.peek(t.maxBits)]
+		stream.pos -= int(e.bits)
+		*out = append(*out, e.symbol)
+	}
+
+	if stream.pos != 0 {
+		return errZstdCorrupted
+	}
+
+	return nil
+}
+
+// xxhash64 - потоковое вычисление XXH64 с нулевым начальным значением, которым проверяется содержимое кадра
+type xxhash64 struct {
+	v      [4]uint64
+	total  uint64
+	buffer [32]byte
+	used   int
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+func newXXHash64() *xxhash64 {
+	// Сложение выполняется по модулю 2^64, поэтому считается не в константах
+	prime1, prime2 := xxPrime1, xxPrime2
+	return &xxhash64{v: [4]uint64{prime1 + prime2, prime2, 0, -prime1}}
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc, value uint64) uint64 {
+	acc ^= xxRound(0, value)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func (h *xxhash64) Write(p []byte) {
+	h.total += uint64(len(p))
+
+	if h.used > 0 {
+		n := copy(h.buffer[h.used:], p)
+		h.used += n
+		p = p[n:]
+
+		if h.used < 32 {
+			return
+		}
+
+		h.stripe(h.buffer[:])
+		h.used = 0
+	}
+
+	for len(p) >= 32 {
+		h.stripe(p[:32])
+		p = p[32:]
+	}
+
+	h.used = copy(h.buffer[:], p)
+}
+
+func (h *xxhash64) stripe(p []byte) {
+	for i := range h.v {
+		h.v[i] = xxRound(h.v[i], binary.LittleEndian.Uint64(p[8*i:]))
+	}
+}
+
+func (h *xxhash64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+
+		for _, v := range h.v {
+			acc = xxMerge(acc, v)
+		}
+	} else {
+		acc = h.v[2] + xxPrime5
+	}
+
+	acc += h.total
+
+	p := h.buffer[:h.used]
+	for ; len(p) >= 8; p = p[8:] {
+		acc ^= xxRound(0, binary.LittleEndian.Uint64(p))
+		acc = bits.RotateLeft64(acc, 27)*xxPrime1 + xxPrime4
+	}
+
+	if len(p) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(p)) * xxPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxPrime2 + xxPrime3
+		p = p[4:]
+	}
+
+	for _, b := range p {
+		acc ^= uint64(b) * xxPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxPrime1
+	}
+
+	acc ^= acc >> 33
+	acc *= xxPrime2
+	acc ^= acc >> 29
+	acc *= xxPrime3
+	acc ^= acc >> 32
+
+	return acc
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// zstdFixture - результат zstd -19 для zstdFixtureText: блок с литералами, сжатыми кодом Хаффмана, и таблицами FSE
+const zstdFixture = "" +
+	"28b52ffd6478002d050052461411907d9cbe84dcecb6bb935d1ee075b58503a0" +
+	"1c050821602d054651a2608bf1837512300705fc799b92aa95da7dadd68132aa" +
+	"ac98719d7fdfc7209515f4b64fa50e165619de4fc960e8583f0e71b77f0125a8" +
+	"a146a8c22c74fd0d10108152567a1010c39106298c0146ce74c4b4b6de85615e" +
+	"8cb011094b71f721718a533b36a9108f382066e62d30bad0fcf262147426ada2" +
+	"dd20673e3d9da249fff9b3333caf0698104c64"
+
+const zstdFixtureText = `debug retry failed info timeout 937
+retry request retry warning retry 13
+request connection failed debug debug 734
+request failed failed request user 654
+info debug info failed user 759
+error warning info retry error 308
+error connection request retry user 731
+user user retry request info 899
+timeout warning error info request 222
+connection user connection user failed 853
+`
+
+func mustHex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestZstdReader(t *testing.T) {
+	frame := mustHex(t, zstdFixture)
+
+	// Пропускаемый кадр с 3 байтами данных
+	skippable := []byte{0x50, 0x2a, 0x4d, 0x18, 3, 0, 0, 0, 1, 2, 3}
+
+	corrupted := append([]byte(nil), frame...)
+	corrupted[len(corrupted)-1] ^= 1
+
+	tests := []struct {
+		input    []byte
+		expected string
+		err      error
+	}{
+		{input: frame, expected: zstdFixtureText},
+		{input: bytes.Join([][]byte{frame, skippable, frame}, nil), expected: zstdFixtureText + zstdFixtureText},
+		{input: nil, expected: ""},
+		{input: corrupted, err: errZstdChecksum},
+		{input: frame[:len(frame)/2], err: io.ErrUnexpectedEOF},
+	}
+
+	for i, c := range tests {
+		actual, err := io.ReadAll(NewZstdReader(bytes.NewReader(c.input)))
+
+		if c.err != nil {
+			if !errors.Is(err, c.err) {
+				t.Errorf("%d: unexpected error: %v (expected %v)", i, err, c.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		} else if string(actual) != c.expected {
+			t.Errorf("%d: unexpected result: %q (expected %q)", i, actual, c.expected)
+		}
+	}
+}
+
+// Сверяет распаковку с эталонной утилитой zstd на данных разного вида и разных уровнях сжатия
+func TestZstdReader_Reference(t *testing.T) {
+	path, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd is not installed")
+	}
+
+	random := rand.New(rand.NewSource(1))
+	words := strings.Fields("error warning info debug ошибка connection timeout user 42 0x1f")
+
+	text := &bytes.Buffer{}
+	for text.Len() < 256<<10 {
+		fmt.Fprintf(text, "%s %s %d\n", words[random.Intn(len(words))], words[random.Intn(len(words))], random.Intn(1000))
+	}
+
+	noise := make([]byte, 64<<10)
+	random.Read(noise)
+
+	inputs := map[string][]byte{
+		"text":  text.Bytes(),
+		"noise": noise,
+		"mixed": append(append([]byte(nil), noise[:32<<10]...), text.Bytes()[:128<<10]...),
+		"runs":  bytes.Repeat([]byte{'a'}, 200<<10),
+	}
+
+	for name, input := range inputs {
+		for _, args := range [][]string{{"-1"}, {"-3"}, {"-19"}, {"-9", "--long=24"}, {"-3", "--no-check"}} {
+			cmd := exec.Command(path, append([]string{"-q", "-c"}, args...)...)
+			cmd.Stdin = bytes.NewReader(input)
+
+			compressed, err := cmd.Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := io.ReadAll(NewZstdReader(bytes.NewReader(compressed)))
+			if err != nil {
+				t.Errorf("%s %v: unexpected error: %v", name, args, err)
+			} else if !bytes.Equal(actual, input) {
+				t.Errorf("%s %v: decompressed data differs from input", name, args)
+			}
+		}
+	}
+}
+
+func TestXXHash64(t *testing.T) {
+	if actual := newXXHash64().Sum64(); actual != 0xef46db3751d8e999 {
+		t.Errorf("unexpected hash of empty input: %x", actual)
+	}
+
+	// Результат не должен зависеть от того, какими частями записаны данные
+	data := []byte(strings.Repeat(zstdFixtureText, 3))
+
+	whole := newXXHash64()
+	whole.Write(data)
+
+	parts := newXXHash64()
+	for i := 0; i < len(data); i += 7 {
+		parts.Write(data[i:min(i+7, len(data))])
+	}
+
+	if whole.Sum64() != parts.Sum64() {
+		t.Errorf("hash depends on write sizes: %x != %x", whole.Sum64(), parts.Sum64())
+	}
+}